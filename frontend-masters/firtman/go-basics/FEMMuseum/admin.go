@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"frontendmasters.com/go/museum/auth"
+	"frontendmasters.com/go/museum/config"
+)
+
+const adminUsage = `Usage:
+  museum admin [-auth-dir dir] keys create -name NAME -role ROLE
+  museum admin [-auth-dir dir] keys list
+  museum admin [-auth-dir dir] keys revoke ID
+  museum admin [-auth-dir dir] token -subject NAME -role ROLE [-ttl 24h]
+
+Roles are viewer, editor and admin.
+`
+
+// runAdmin manages API keys and issues tokens on the machine running the
+// server; there's no HTTP endpoint for either.
+func runAdmin(args []string, stdout, stderr io.Writer) int {
+	if err := adminCommand(args, stdout, stderr); err != nil {
+		fmt.Fprintln(stderr, err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(stderr, adminUsage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func adminCommand(args []string, stdout, stderr io.Writer) error {
+	cfg, err := config.Load(nil, os.Getenv)
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&cfg.AuthDir, "auth-dir", cfg.AuthDir, "")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "keys":
+		if len(args) < 2 {
+			return errUsage
+		}
+		keys, err := auth.OpenKeys(filepath.Join(cfg.AuthDir, "keys.json"))
+		if err != nil {
+			return err
+		}
+		return adminKeys(keys, args[1], args[2:], stdout)
+	case "token":
+		signer, err := auth.LoadSigner(filepath.Join(cfg.AuthDir, "token.key"))
+		if err != nil {
+			return err
+		}
+		return adminToken(signer, args[1:], stdout, stderr)
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+}
+
+func adminKeys(keys *auth.KeyStore, command string, args []string, stdout io.Writer) error {
+	switch command {
+	case "create":
+		flags := flag.NewFlagSet("keys create", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		name := flags.String("name", "", "")
+		roleName := flags.String("role", "", "")
+		if err := flags.Parse(args); err != nil || *name == "" {
+			return fmt.Errorf("%w: keys create needs -name and -role", errUsage)
+		}
+		role, err := auth.ParseRole(*roleName)
+		if err != nil {
+			return err
+		}
+		secret, key, err := keys.Create(*name, role)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created key %s for %s (%s). It won't be shown again:\n%s\n", key.ID, key.Name, key.Role, secret)
+		return nil
+	case "list":
+		list, err := keys.List()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tROLE\tCREATED")
+		for _, k := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Role, k.Created.Format(time.RFC3339))
+		}
+		return tw.Flush()
+	case "revoke":
+		if len(args) != 1 {
+			return fmt.Errorf("%w: keys revoke needs the key ID", errUsage)
+		}
+		if err := keys.Revoke(args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Revoked key %s\n", args[0])
+		return nil
+	}
+	return fmt.Errorf("%w: unknown keys command %q", errUsage, command)
+}
+
+// adminToken prints only the token to stdout, so it can be captured by a
+// script.
+func adminToken(signer *auth.Signer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	subject := flags.String("subject", "", "")
+	roleName := flags.String("role", "", "")
+	ttl := flags.Duration("ttl", 24*time.Hour, "")
+	if err := flags.Parse(args); err != nil || *subject == "" || *ttl <= 0 {
+		return fmt.Errorf("%w: token needs -subject, -role and a positive -ttl", errUsage)
+	}
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+	token, claims, err := signer.Issue(*subject, role, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, token)
+	fmt.Fprintf(stderr, "Token %s for %s (%s), valid until %s\n",
+		claims.ID, claims.Subject, claims.Role, time.Unix(claims.ExpiresAt, 0).Format(time.RFC3339))
+	return nil
+}
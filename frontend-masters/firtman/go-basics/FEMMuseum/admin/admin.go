@@ -0,0 +1,343 @@
+// Package admin is the server-rendered area where curators manage
+// exhibitions without writing JSON by hand.
+package admin
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"frontendmasters.com/go/museum/auth"
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/gallery"
+	"frontendmasters.com/go/museum/middleware"
+	"frontendmasters.com/go/museum/render"
+	"frontendmasters.com/go/museum/validation"
+)
+
+// maxFormBytes bounds the size of a submitted form.
+const maxFormBytes = 64 << 10
+
+type Options struct {
+	Renderer  *render.Renderer
+	Auth      *auth.Authenticator
+	Validator *validation.Validator
+	// Gallery lists the images curators can pick from.
+	Gallery fs.FS
+	// Images finds the generated copies of uploaded images; optional.
+	Images *gallery.Store
+	// SecureCookies marks the session cookie Secure. Only leave it off
+	// when serving over plain HTTP in development.
+	SecureCookies bool
+}
+
+// Admin serves the admin area under /admin. Curators log in with an API
+// key or token with at least the editor role.
+type Admin struct {
+	renderer  *render.Renderer
+	auth      *auth.Authenticator
+	validator *validation.Validator
+	gallery   fs.FS
+	images    *gallery.Store
+	secure    bool
+	sessions  *sessions
+	mux       *http.ServeMux
+}
+
+func New(opts Options) *Admin {
+	a := &Admin{
+		renderer:  opts.Renderer,
+		auth:      opts.Auth,
+		validator: opts.Validator,
+		gallery:   opts.Gallery,
+		images:    opts.Images,
+		secure:    opts.SecureCookies,
+		sessions:  newSessions(),
+		mux:       http.NewServeMux(),
+	}
+	a.mux.HandleFunc("GET /admin/login", a.loginForm)
+	a.mux.HandleFunc("POST /admin/login", a.csrf(a.login))
+	a.mux.HandleFunc("POST /admin/logout", a.csrf(a.logout))
+	a.mux.HandleFunc("GET /admin", a.require(a.list))
+	a.mux.HandleFunc("GET /admin/exhibitions/new", a.require(a.newForm))
+	a.mux.HandleFunc("POST /admin/exhibitions", a.require(a.csrf(a.create)))
+	a.mux.HandleFunc("GET /admin/exhibitions/{id}/edit", a.require(a.editForm))
+	a.mux.HandleFunc("POST /admin/exhibitions/{id}", a.require(a.csrf(a.update)))
+	a.mux.HandleFunc("POST /admin/exhibitions/{id}/delete", a.require(a.csrf(a.delete)))
+	return a
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Content-Security-Policy", "frame-ancestors 'none'")
+	h.Set("Referrer-Policy", "same-origin")
+	a.mux.ServeHTTP(w, a.withSession(w, r))
+}
+
+// page is what the admin templates render.
+type page struct {
+	User        string
+	CSRF        string
+	Flashes     []Flash
+	Exhibitions []data.Exhibition
+	// The exhibition being edited, with the submitted values after a
+	// failed submission.
+	Exhibition data.Exhibition
+	Errors     map[string]string // field -> message
+	Action     string            // where the form posts to
+	Images     []string
+	Palette    []string
+}
+
+func (a *Admin) render(w http.ResponseWriter, r *http.Request, status int, name string, p page) {
+	sess := a.session(w, r)
+	p.CSRF = sess.csrf
+	p.Flashes = a.sessions.takeFlashes(sess)
+	if sess.principal != nil {
+		p.User = sess.principal.Subject
+	}
+	a.renderer.Render(w, status, name, p)
+}
+
+func (a *Admin) redirect(w http.ResponseWriter, r *http.Request, url, kind, message string) {
+	if message != "" {
+		a.sessions.flash(a.session(w, r), kind, message)
+	}
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// require only lets logged in editors through.
+func (a *Admin) require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess := a.session(w, r)
+		if sess.principal == nil {
+			a.redirect(w, r, "/admin/login", "", "")
+			return
+		}
+		ctx := auth.WithPrincipal(r.Context(), *sess.principal)
+		next(w, r.WithContext(data.WithAuthor(ctx, sess.principal.Subject)))
+	}
+}
+
+// csrf rejects form submissions without the token of the session.
+func (a *Admin) csrf(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "The form couldn't be read", http.StatusBadRequest)
+			return
+		}
+		if !a.session(w, r).validCSRF(r.PostForm.Get("csrf")) {
+			a.reject(r, http.StatusForbidden, "missing or wrong CSRF token", auth.Principal{})
+			http.Error(w, "This form has expired. Go back, reload the page and try again.", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (a *Admin) reject(r *http.Request, status int, reason string, p auth.Principal) {
+	err := a.auth.Audit.Record(auth.Rejection{
+		RequestID: middleware.RequestIDFrom(r.Context()),
+		Remote:    r.RemoteAddr,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    status,
+		Reason:    reason,
+		Subject:   p.Subject,
+		Role:      p.Role,
+	})
+	if err != nil {
+		log.Printf("Couldn't write to the audit log: %v", err)
+	}
+}
+
+func (a *Admin) loginForm(w http.ResponseWriter, r *http.Request) {
+	if a.session(w, r).principal != nil {
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return
+	}
+	a.render(w, r, http.StatusOK, "admin-login", page{})
+}
+
+func (a *Admin) login(w http.ResponseWriter, r *http.Request) {
+	principal, err := a.auth.Verify(strings.TrimSpace(r.PostForm.Get("key")))
+	message := ""
+	switch {
+	case err != nil:
+		a.reject(r, http.StatusUnauthorized, err.Error(), auth.Principal{})
+		message = "That key or token isn't valid."
+	case !principal.Role.Allows(auth.Editor):
+		a.reject(r, http.StatusForbidden, "requires role editor", principal)
+		message = "Your key doesn't allow editing exhibitions."
+	}
+	if message != "" {
+		a.render(w, r, http.StatusUnauthorized, "admin-login", page{Errors: map[string]string{"key": message}})
+		return
+	}
+	sess := a.sessions.login(a.session(w, r), principal)
+	a.setCookie(w, sess)
+	a.sessions.flash(sess, "success", "Welcome, "+principal.Subject+".")
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (a *Admin) logout(w http.ResponseWriter, r *http.Request) {
+	a.sessions.delete(a.session(w, r).id)
+	a.clearCookie(w)
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+func (a *Admin) list(w http.ResponseWriter, r *http.Request) {
+	a.render(w, r, http.StatusOK, "admin", page{Exhibitions: data.GetAll()})
+}
+
+func (a *Admin) newForm(w http.ResponseWriter, r *http.Request) {
+	a.form(w, r, http.StatusOK, data.Exhibition{}, nil)
+}
+
+func (a *Admin) editForm(w http.ResponseWriter, r *http.Request) {
+	e, err := data.Get(r.PathValue("id"))
+	if err != nil {
+		a.redirect(w, r, "/admin", "error", "That exhibition doesn't exist anymore.")
+		return
+	}
+	a.form(w, r, http.StatusOK, e, nil)
+}
+
+func (a *Admin) form(w http.ResponseWriter, r *http.Request, status int, e data.Exhibition, errs map[string]string) {
+	action := "/admin/exhibitions"
+	if e.ID != "" {
+		action += "/" + e.ID
+	}
+	a.render(w, r, status, "admin-form", page{
+		Exhibition: e,
+		Errors:     errs,
+		Action:     action,
+		Images:     a.galleryImages(),
+		Palette:    validation.Palette,
+	})
+}
+
+func (a *Admin) create(w http.ResponseWriter, r *http.Request) {
+	e := a.fromForm(r, data.Exhibition{})
+	if !a.valid(w, r, e) {
+		return
+	}
+	created, err := data.Add(r.Context(), e)
+	if err != nil {
+		a.storeError(w, r, e, err)
+		return
+	}
+	a.redirect(w, r, "/admin", "success", "Created “"+created.Title+"”.")
+}
+
+func (a *Admin) update(w http.ResponseWriter, r *http.Request) {
+	// The form is applied to the exhibition as stored when it's written,
+	// so the fields it doesn't have keep what a concurrent write put there.
+	var e data.Exhibition
+	var invalid error
+	updated, err := data.Modify(r.Context(), r.PathValue("id"), func(current data.Exhibition) (data.Exhibition, error) {
+		e = a.fromForm(r, current)
+		invalid = a.validator.Exhibition(e)
+		return e, invalid
+	})
+	if invalid != nil {
+		a.invalid(w, r, e, invalid)
+		return
+	}
+	if err != nil {
+		a.storeError(w, r, e, err)
+		return
+	}
+	a.redirect(w, r, "/admin", "success", "Saved “"+updated.Title+"”.")
+}
+
+func (a *Admin) delete(w http.ResponseWriter, r *http.Request) {
+	e, err := data.Get(r.PathValue("id"))
+	if err == nil {
+		err = data.Delete(r.Context(), e.ID)
+	}
+	if err != nil {
+		a.redirect(w, r, "/admin", "error", "That exhibition doesn't exist anymore.")
+		return
+	}
+	a.redirect(w, r, "/admin", "success", "Deleted “"+e.Title+"”.")
+}
+
+// fromForm applies the submitted fields to e. Fields the form doesn't have,
+// like the schedule, are kept.
+func (a *Admin) fromForm(r *http.Request, e data.Exhibition) data.Exhibition {
+	form := r.PostForm
+	e.Title = strings.TrimSpace(form.Get("title"))
+	e.Slug = strings.TrimSpace(form.Get("slug"))
+	e.Description = strings.TrimSpace(form.Get("description"))
+	if image := form.Get("image"); image != e.Image {
+		e.Image = image
+		e.Variants = nil
+		if a.images != nil {
+			e.Variants = a.images.Variants(image)
+		}
+	}
+	e.Color = form.Get("color")
+	if e.Schedule == nil {
+		e.CurrentlyOpened = form.Get("opened") == "on"
+	}
+	return e
+}
+
+// valid checks e with the API's validator and shows the form again with
+// the problems when there are any.
+func (a *Admin) valid(w http.ResponseWriter, r *http.Request, e data.Exhibition) bool {
+	err := a.validator.Exhibition(e)
+	if err == nil {
+		return true
+	}
+	a.invalid(w, r, e, err)
+	return false
+}
+
+// invalid shows the form again with the problems err found in e.
+func (a *Admin) invalid(w http.ResponseWriter, r *http.Request, e data.Exhibition, err error) {
+	errs := map[string]string{}
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		for _, fe := range fieldErrs {
+			if errs[fe.Field] == "" {
+				errs[fe.Field] = fe.Message
+			}
+		}
+	} else {
+		errs["Title"] = err.Error()
+	}
+	a.form(w, r, http.StatusUnprocessableEntity, e, errs)
+}
+
+func (a *Admin) storeError(w http.ResponseWriter, r *http.Request, e data.Exhibition, err error) {
+	switch {
+	case errors.Is(err, data.ErrSlugTaken):
+		a.form(w, r, http.StatusConflict, e, map[string]string{"Slug": "is already used by another exhibition"})
+	case errors.Is(err, data.ErrNotFound):
+		a.redirect(w, r, "/admin", "error", "That exhibition doesn't exist anymore.")
+	default:
+		log.Printf("Couldn't save exhibition %q: %v", e.Title, err)
+		a.form(w, r, http.StatusInternalServerError, e, map[string]string{"Title": "couldn't be saved, try again"})
+	}
+}
+
+// galleryImages lists the images in the gallery, leaving out the copies
+// generated for uploads and the alternative formats served in their place.
+func (a *Admin) galleryImages() []string {
+	var images []string
+	fs.WalkDir(a.gallery, ".", func(name string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && gallery.IsOriginal(name) {
+			images = append(images, name)
+		}
+		return nil
+	})
+	slices.Sort(images)
+	return images
+}
@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"frontendmasters.com/go/museum/auth"
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/render"
+	"frontendmasters.com/go/museum/validation"
+)
+
+type client struct {
+	t      *testing.T
+	server *httptest.Server
+	http   *http.Client
+	csrf   string
+}
+
+// newClient serves the admin area over a fresh store and returns a browser
+// for it, along with an editor key.
+func newClient(t *testing.T) (*client, string) {
+	t.Helper()
+	data.Use(data.NewMemoryRepository())
+	authenticator, err := auth.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { authenticator.Audit.Close() })
+	key, _, err := authenticator.Keys.Create("curator", auth.Editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := render.New(os.DirFS("../templates"), false, template.FuncMap{
+		"asset": func(name string) string { return "/" + name },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gallery := fstest.MapFS{"rock.png": {}, "rock-thumb.png": {}, "rock.webp": {}}
+	server := httptest.NewServer(New(Options{
+		Renderer:  renderer,
+		Auth:      authenticator,
+		Validator: validation.New(gallery),
+		Gallery:   gallery,
+	}))
+	t.Cleanup(server.Close)
+	jar, _ := cookiejar.New(nil)
+	return &client{t: t, server: server, http: &http.Client{Jar: jar}}, key
+}
+
+var csrfField = regexp.MustCompile(`name="csrf" value="([^"]+)"`)
+
+// do sends a request and remembers the CSRF token of the page it ends on.
+func (c *client) do(method, path string, form url.Values) (int, string) {
+	c.t.Helper()
+	var res *http.Response
+	var err error
+	if method == http.MethodGet {
+		res, err = c.http.Get(c.server.URL + path)
+	} else {
+		res, err = c.http.PostForm(c.server.URL+path, form)
+	}
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if m := csrfField.FindSubmatch(body); m != nil {
+		c.csrf = string(m[1])
+	}
+	return res.StatusCode, string(body)
+}
+
+func (c *client) login(key string) {
+	c.t.Helper()
+	c.do(http.MethodGet, "/admin/login", nil)
+	status, body := c.do(http.MethodPost, "/admin/login", url.Values{"csrf": {c.csrf}, "key": {key}})
+	if status != http.StatusOK || !strings.Contains(body, "Welcome, curator.") {
+		c.t.Fatalf("logging in got %d: %s", status, body)
+	}
+}
+
+func TestRequiresLogin(t *testing.T) {
+	c, _ := newClient(t)
+	status, body := c.do(http.MethodGet, "/admin", nil)
+	if status != http.StatusOK || !strings.Contains(body, `action="/admin/login"`) {
+		t.Fatalf("got %d: %s", status, body)
+	}
+
+	status, body = c.do(http.MethodPost, "/admin/login", url.Values{"csrf": {c.csrf}, "key": {"mk_nope_00"}})
+	if status != http.StatusUnauthorized || !strings.Contains(body, "isn&#39;t valid") {
+		t.Fatalf("a wrong key got %d: %s", status, body)
+	}
+}
+
+func TestRejectsFormsWithoutCSRFToken(t *testing.T) {
+	c, key := newClient(t)
+	c.login(key)
+	form := url.Values{"title": {"Rocks"}, "description": {"Old ones"}, "image": {"rock.png"}}
+	if status, _ := c.do(http.MethodPost, "/admin/exhibitions", form); status != http.StatusForbidden {
+		t.Fatalf("got %d without a token", status)
+	}
+	form.Set("csrf", "forged")
+	if status, _ := c.do(http.MethodPost, "/admin/exhibitions", form); status != http.StatusForbidden {
+		t.Fatalf("got %d with a wrong token", status)
+	}
+	if len(data.GetAll()) != 0 {
+		t.Fatal("the exhibition was created anyway")
+	}
+}
+
+func TestVisitorsKeepNoSessions(t *testing.T) {
+	a := New(Options{Auth: &auth.Authenticator{}})
+	for range 100 {
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("got %d", w.Code)
+		}
+	}
+	if len(a.sessions.byID) != 0 {
+		t.Errorf("got %d sessions for visitors who didn't log in", len(a.sessions.byID))
+	}
+}
+
+func TestLoginRefusesAPlantedCSRFCookie(t *testing.T) {
+	c, key := newClient(t)
+	// A token the server didn't sign, set in the cookie and the form alike.
+	server, _ := url.Parse(c.server.URL)
+	c.http.Jar.SetCookies(server, []*http.Cookie{{Name: "museum_csrf", Value: "planted.token", Path: "/"}})
+	status, _ := c.do(http.MethodPost, "/admin/login", url.Values{"csrf": {"planted.token"}, "key": {key}})
+	if status != http.StatusForbidden {
+		t.Fatalf("got %d with a planted token", status)
+	}
+}
+
+func TestCreateEditDelete(t *testing.T) {
+	c, key := newClient(t)
+	c.login(key)
+
+	c.do(http.MethodGet, "/admin/exhibitions/new", nil)
+	status, body := c.do(http.MethodPost, "/admin/exhibitions", url.Values{
+		"csrf": {c.csrf}, "title": {"Rocks"}, "image": {"missing.png"}, "color": {"pink"},
+	})
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid fields got %d", status)
+	}
+	for _, want := range []string{"is required", "doesn&#39;t exist in the gallery", "must be one of", `value="Rocks"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the form doesn't show %q: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "rock-thumb.png") || strings.Contains(body, "rock.webp") {
+		t.Error("the image picker lists generated files")
+	}
+
+	status, body = c.do(http.MethodPost, "/admin/exhibitions", url.Values{
+		"csrf": {c.csrf}, "title": {"Rocks"}, "description": {"Old ones"}, "image": {"rock.png"}, "color": {"red"}, "opened": {"on"},
+	})
+	if status != http.StatusOK || !strings.Contains(body, "Created “Rocks”.") {
+		t.Fatalf("creating got %d: %s", status, body)
+	}
+	e, err := data.GetBySlug("rocks")
+	if err != nil || !e.CurrentlyOpened || e.Color != "red" {
+		t.Fatalf("stored %+v, %v", e, err)
+	}
+	if revisions, _ := data.Revisions(e.ID); revisions[len(revisions)-1].Author != "curator" {
+		t.Errorf("the change is by %q", revisions[len(revisions)-1].Author)
+	}
+
+	status, body = c.do(http.MethodPost, "/admin/exhibitions/"+e.ID, url.Values{
+		"csrf": {c.csrf}, "title": {"Rocks"}, "description": {"Very old ones"}, "image": {"rock.png"}, "color": {"red"},
+	})
+	if status != http.StatusOK || !strings.Contains(body, "Saved “Rocks”.") {
+		t.Fatalf("editing got %d: %s", status, body)
+	}
+	if e, _ = data.Get(e.ID); e.Description != "Very old ones" || e.CurrentlyOpened {
+		t.Fatalf("stored %+v", e)
+	}
+
+	status, body = c.do(http.MethodPost, "/admin/exhibitions/"+e.ID+"/delete", url.Values{"csrf": {c.csrf}})
+	if status != http.StatusOK || !strings.Contains(body, "Deleted “Rocks”.") || len(data.GetAll()) != 0 {
+		t.Fatalf("deleting got %d: %s", status, body)
+	}
+}
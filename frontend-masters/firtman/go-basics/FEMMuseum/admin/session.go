@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"frontendmasters.com/go/museum/auth"
+)
+
+const (
+	sessionTTL = 8 * time.Hour
+	// maxSessions bounds the sessions kept; beyond it the ones closest to
+	// expiring are dropped.
+	maxSessions = 10000
+	// sweepEvery is how often expired sessions are looked for.
+	sweepEvery = time.Minute
+)
+
+// Flash is a message shown once, on the next page rendered.
+type Flash struct {
+	Kind    string // "success" or "error"
+	Message string
+}
+
+// session is kept on the server once logged in; the cookie only holds its
+// random ID. Visitors who haven't logged in get one that isn't kept, whose
+// CSRF token comes from a signed cookie instead, so they cost no memory.
+type session struct {
+	id        string
+	csrf      string
+	principal *auth.Principal
+	flashes   []Flash
+	expires   time.Time
+}
+
+type sessions struct {
+	// key signs the CSRF tokens of visitors.
+	key []byte
+
+	mu        sync.Mutex
+	byID      map[string]*session
+	nextSweep time.Time
+}
+
+func newSessions() *sessions {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &sessions{key: key, byID: make(map[string]*session)}
+}
+
+// create starts an empty session. The expired ones are dropped now and
+// then, and the oldest ones when there are too many.
+func (s *sessions) create() *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.After(s.nextSweep) || len(s.byID) >= maxSessions {
+		for id, sess := range s.byID {
+			if now.After(sess.expires) {
+				delete(s.byID, id)
+			}
+		}
+		s.nextSweep = now.Add(sweepEvery)
+	}
+	for len(s.byID) >= maxSessions {
+		var oldest *session
+		for _, sess := range s.byID {
+			if oldest == nil || sess.expires.Before(oldest.expires) {
+				oldest = sess
+			}
+		}
+		delete(s.byID, oldest.id)
+	}
+	sess := &session{id: randomToken(), csrf: randomToken(), expires: now.Add(sessionTTL)}
+	s.byID[sess.id] = sess
+	return sess
+}
+
+func (s *sessions) get(id string) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.byID[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.byID, id)
+		return nil
+	}
+	return sess
+}
+
+// visitorToken returns a new CSRF token for a visitor: a random value and
+// its signature, so a token planted in the cookie by someone else is
+// refused.
+func (s *sessions) visitorToken() string {
+	value := randomToken()
+	return value + "." + s.sign(value)
+}
+
+func (s *sessions) validVisitorToken(token string) bool {
+	value, signature, ok := strings.Cut(token, ".")
+	return ok && hmac.Equal([]byte(signature), []byte(s.sign(value)))
+}
+
+func (s *sessions) sign(value string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *sessions) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byID, id)
+}
+
+// login replaces old, which may be a visitor's, with a new authenticated
+// session, so an ID planted before logging in is worthless afterwards.
+func (s *sessions) login(old *session, p auth.Principal) *session {
+	sess := s.create()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.principal = &p
+	sess.flashes = old.flashes
+	delete(s.byID, old.id)
+	return sess
+}
+
+func (s *sessions) flash(sess *session, kind, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.flashes = append(sess.flashes, Flash{Kind: kind, Message: message})
+}
+
+func (s *sessions) takeFlashes(sess *session) []Flash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes := sess.flashes
+	sess.flashes = nil
+	return flashes
+}
+
+func (sess *session) validCSRF(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(sess.csrf)) == 1
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // the system has no randomness left; nothing is safe
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// cookieName gets the __Host- prefix when it's Secure, which makes browsers
+// refuse it unless it's also host-only and for the whole site.
+func (a *Admin) cookieName() string {
+	if a.secure {
+		return "__Host-museum_session"
+	}
+	return "museum_session"
+}
+
+func (a *Admin) setCookie(w http.ResponseWriter, sess *session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cookieName(),
+		Value:    sess.id,
+		Path:     "/",
+		Expires:  sess.expires,
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// visitorCookieName is the cookie holding the CSRF token of a visitor.
+func (a *Admin) visitorCookieName() string {
+	if a.secure {
+		return "__Host-museum_csrf"
+	}
+	return "museum_csrf"
+}
+
+func (a *Admin) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cookieName(),
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+type sessionKey struct{}
+
+// session returns the session of r. Without one it's a visitor's, which
+// isn't kept and only has a CSRF token, from its cookie or a new one.
+func (a *Admin) session(w http.ResponseWriter, r *http.Request) *session {
+	if sess, ok := r.Context().Value(sessionKey{}).(*session); ok {
+		return sess
+	}
+	if cookie, err := r.Cookie(a.cookieName()); err == nil {
+		if sess := a.sessions.get(cookie.Value); sess != nil {
+			return sess
+		}
+	}
+	if cookie, err := r.Cookie(a.visitorCookieName()); err == nil && a.sessions.validVisitorToken(cookie.Value) {
+		return &session{csrf: cookie.Value}
+	}
+	sess := &session{csrf: a.sessions.visitorToken()}
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.visitorCookieName(),
+		Value:    sess.csrf,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return sess
+}
+
+// withSession remembers the session of r for the rest of the request, so a
+// new one is only started once.
+func (a *Admin) withSession(w http.ResponseWriter, r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionKey{}, a.session(w, r)))
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/transfer"
+)
+
+// MaxImportBytes bounds the size of an uploaded import file.
+const MaxImportBytes = 10 << 20
+
+// Import serves POST /api/admin/import. The body is a JSON array or, with
+// Content-Type text/csv, a CSV file. ?match=title|id picks how records are
+// matched, ?overwrite=true replaces differing exhibitions and ?dryRun=true
+// only reports what would happen.
+func Import(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := transfer.Options{
+		MatchBy:  query.Get("match"),
+		Validate: validator.Exhibition,
+		Variants: variantsFor,
+	}
+	if opts.MatchBy == "" {
+		opts.MatchBy = transfer.MatchByTitle
+	}
+	for name, target := range map[string]*bool{"overwrite": &opts.Overwrite, "dryRun": &opts.DryRun} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				writeProblem(w, r, problem(http.StatusBadRequest, name+" must be true or false"))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, MaxImportBytes)
+	var records []transfer.Record
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		records, err = transfer.ReadCSV(body)
+	case "application/json", "":
+		records, err = transfer.ReadJSON(body)
+	default:
+		writeProblem(w, r, problem(http.StatusUnsupportedMediaType, "send application/json or text/csv"))
+		return
+	}
+	if err != nil {
+		writeProblem(w, r, Problem{Type: problemMalformed, Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+
+	report, err := transfer.Merge(r.Context(), records, opts)
+	if err != nil {
+		// Merge only fails before writing for bad options; later it's the
+		// store, and the partial report tells what got in.
+		if report == nil {
+			writeProblem(w, r, problem(http.StatusBadRequest, err.Error()))
+			return
+		}
+		p := storeProblem(err)
+		p.Detail = "the import stopped; the report tells what got in before"
+		p.Report = report
+		writeProblem(w, r, p)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Export serves GET /api/admin/export?format=json|csv.
+func Export(w http.ResponseWriter, r *http.Request) {
+	list := data.GetAll()
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="exhibitions.csv"`)
+		transfer.WriteCSV(w, list)
+	case "json", "":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="exhibitions.json"`)
+		transfer.WriteJSON(w, list)
+	default:
+		writeProblem(w, r, problem(http.StatusBadRequest, "format must be json or csv"))
+	}
+}
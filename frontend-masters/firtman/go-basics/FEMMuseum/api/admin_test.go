@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// fullRepository takes one exhibition and then fails to write.
+type fullRepository struct {
+	*data.MemoryRepository
+}
+
+func (f fullRepository) Add(e data.Exhibition) (data.Exhibition, error) {
+	if len(f.GetAll()) > 0 {
+		return data.Exhibition{}, errors.New("disk full")
+	}
+	return f.MemoryRepository.Add(e)
+}
+
+func TestImportReportsWhatGotInBeforeFailing(t *testing.T) {
+	data.Use(fullRepository{data.NewMemoryRepository()})
+	defer data.Use(data.NewMemoryRepository())
+
+	body := `[{"title": "Amber", "description": "Fossil resin", "image": "amber.jpg"}, {"title": "Jade", "description": "Green stone", "image": "jade.jpg"}]`
+	r := httptest.NewRequest("POST", "/api/admin/import", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	Import(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Report == nil || len(p.Report.Created) != 1 || p.Report.Created[0].Title != "Amber" {
+		t.Errorf("got report %+v", p.Report)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"frontendmasters.com/go/museum/auth"
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/middleware"
+)
+
+var authenticator *auth.Authenticator
+
+// UseAuth sets how the write endpoints authenticate clients. Without it
+// they answer 503. Call it at startup, before the server starts handling
+// requests.
+func UseAuth(a *auth.Authenticator) {
+	authenticator = a
+}
+
+type credentialsKey struct{}
+
+// credentials is who a request authenticated as, or why it didn't.
+type credentials struct {
+	principal auth.Principal
+	err       error
+}
+
+// Authenticate checks the credentials of requests once, before next, for
+// Identify and the protected routes to share. Wrap the API in it outside
+// the rate limiter.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authenticator == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var c credentials
+		c.principal, c.err = authenticator.Authenticate(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), credentialsKey{}, c)))
+	})
+}
+
+// authenticate returns who r authenticated as, checking its credentials
+// only when Authenticate didn't already.
+func authenticate(r *http.Request) (auth.Principal, error) {
+	if c, ok := r.Context().Value(credentialsKey{}).(credentials); ok {
+		return c.principal, c.err
+	}
+	return authenticator.Authenticate(r)
+}
+
+// Identify names the client a request authenticates as, for telling clients
+// apart when counting their requests.
+func Identify(r *http.Request) (string, bool) {
+	if authenticator == nil {
+		return "", false
+	}
+	principal, err := authenticate(r)
+	if err != nil {
+		return "", false
+	}
+	return principal.Method + " " + principal.Subject, true
+}
+
+// require only lets clients with at least role through to next, and
+// records everyone else in the audit log.
+func require(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authenticator == nil {
+			writeProblem(w, r, problem(http.StatusServiceUnavailable, "authentication is not configured"))
+			return
+		}
+		principal, err := authenticate(r)
+		if err != nil {
+			detail := "send an API key or token in the Authorization header"
+			switch {
+			case errors.Is(err, auth.ErrExpiredToken):
+				detail = "the token has expired"
+			case !errors.Is(err, auth.ErrNoCredentials):
+				detail = "the credentials are not valid"
+			}
+			reject(r, http.StatusUnauthorized, err.Error(), auth.Principal{})
+			w.Header().Set("WWW-Authenticate", `Bearer realm="museum"`)
+			writeProblem(w, r, Problem{Type: problemAuth, Title: "You need to authenticate", Status: http.StatusUnauthorized, Detail: detail})
+			return
+		}
+		if !principal.Role.Allows(role) {
+			reject(r, http.StatusForbidden, "requires role "+string(role), principal)
+			writeProblem(w, r, Problem{
+				Type:   problemForbidden,
+				Title:  "You are not allowed to do this",
+				Status: http.StatusForbidden,
+				Detail: "this requires the " + string(role) + " role, you have " + string(principal.Role),
+			})
+			return
+		}
+		ctx := auth.WithPrincipal(r.Context(), principal)
+		next(w, r.WithContext(data.WithAuthor(ctx, principal.Subject)))
+	}
+}
+
+func reject(r *http.Request, status int, reason string, p auth.Principal) {
+	err := authenticator.Audit.Record(auth.Rejection{
+		RequestID: middleware.RequestIDFrom(r.Context()),
+		Remote:    r.RemoteAddr,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    status,
+		Reason:    reason,
+		Subject:   p.Subject,
+		Role:      p.Role,
+	})
+	if err != nil {
+		log.Printf("Couldn't write to the audit log: %v", err)
+	}
+}
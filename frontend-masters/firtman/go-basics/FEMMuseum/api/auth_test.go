@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"frontendmasters.com/go/museum/auth"
+)
+
+func TestCredentialsAreCheckedOnce(t *testing.T) {
+	a, err := auth.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Audit.Close()
+	UseAuth(a)
+	defer UseAuth(nil)
+	secret, _, err := a.Keys.Create("ci", auth.Editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var client string
+	var served bool
+	protected := require(auth.Editor, func(w http.ResponseWriter, r *http.Request) { served = true })
+	handler := Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _ = Identify(r)
+		// Looked up again, the credentials would now be missing.
+		r.Header.Del("X-API-Key")
+		protected(w, r)
+	}))
+	r := httptest.NewRequest("POST", "/api/exhibitions", nil)
+	r.Header.Set("X-API-Key", secret)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if !served || client != "key ci" {
+		t.Errorf("got %d, client %q, want the checked credentials reused", w.Code, client)
+	}
+}
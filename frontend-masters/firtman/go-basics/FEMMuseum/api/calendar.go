@@ -0,0 +1,37 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/i18n"
+	"frontendmasters.com/go/museum/ical"
+)
+
+// Calendar serves GET /api/exhibitions.ics, the schedules of the
+// exhibitions as an iCalendar feed calendar apps can subscribe to.
+func Calendar(w http.ResponseWriter, r *http.Request) {
+	all := data.GetAll()
+	lang, ok := language(w, r, i18n.Languages(all))
+	if !ok {
+		return
+	}
+	if storeNotModified(w, r, lang) {
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="exhibitions.ics"`)
+	err := ical.Write(w, localize(all, lang), ical.Options{
+		Name:     "Museum exhibitions",
+		BaseURL:  scheme + "://" + r.Host,
+		Location: data.Location(),
+	})
+	if err != nil {
+		log.Printf("Couldn't write the calendar: %v", err)
+	}
+}
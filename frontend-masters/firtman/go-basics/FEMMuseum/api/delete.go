@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// Delete serves DELETE /api/exhibitions/{id}.
+func Delete(w http.ResponseWriter, r *http.Request) {
+	if err := data.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
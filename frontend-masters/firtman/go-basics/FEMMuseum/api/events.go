@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"frontendmasters.com/go/museum/events"
+)
+
+var broker *events.Broker
+
+// UseEvents sets the broker behind /api/exhibitions/events. Call it at
+// startup, before the server starts handling requests.
+func UseEvents(b *events.Broker) {
+	broker = b
+}
+
+// Events serves GET /api/exhibitions/events, a Server-Sent Events stream
+// of created, updated and deleted exhibitions.
+func Events(w http.ResponseWriter, r *http.Request) {
+	if broker == nil {
+		writeProblem(w, r, problem(http.StatusServiceUnavailable, "the event stream is not enabled"))
+		return
+	}
+	broker.ServeHTTP(w, r)
+}
@@ -1,25 +1,70 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/i18n"
+	"frontendmasters.com/go/museum/stats"
 )
 
+// List serves GET /api/exhibitions. Without a limit every matching
+// exhibition is returned, like before pagination existed.
+func List(w http.ResponseWriter, r *http.Request) {
+	// api/exhibitions?id=34 is the old way of reading one exhibition by
+	// its position in the list
+	if id := r.URL.Query()["id"]; id != nil {
+		getByIndex(w, r, id[0])
+		return
+	}
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		p := invalid(err)
+		p.Status = http.StatusBadRequest
+		writeProblem(w, r, p)
+		return
+	}
+	lang, ok := language(w, r, i18n.Languages(data.GetAll()))
+	if !ok {
+		return
+	}
+	if storeNotModified(w, r, lang) {
+		return
+	}
+	page := data.Find(q)
+	writePageHeaders(w, r, page)
+	writeJSON(w, http.StatusOK, localize(page.Items, lang))
+}
+
+// Get serves GET /api/exhibitions/{id}.
 func Get(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	// api/exhibitions?id=34
-	id := r.URL.Query()["id"]
-	if id != nil { // We will try to only serve 1
-		finalId, err := strconv.Atoi(id[0])
-		if err == nil && finalId < len(data.GetAll()) {
-			json.NewEncoder(w).Encode(data.GetAll()[finalId])
-		} else {
-			http.Error(w, "Invalid Exhibition", http.StatusBadRequest)
-		}
-	} else { // We want all
-		json.NewEncoder(w).Encode(data.GetAll())
+	exhibition, err := data.Get(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	lang, ok := language(w, r, i18n.Languages([]data.Exhibition{exhibition}))
+	if !ok {
+		return
+	}
+	if recorder != nil {
+		recorder.View(r, exhibition.ID, stats.API)
+	}
+	if lang != "" {
+		exhibition = i18n.Localize(exhibition, lang)
+	}
+	writeJSONWithETag(w, r, exhibition)
+}
+
+func getByIndex(w http.ResponseWriter, r *http.Request, id string) {
+	all := data.GetAll()
+	index, err := strconv.Atoi(id)
+	if err != nil || index < 0 || index >= len(all) {
+		writeProblem(w, r, problem(http.StatusBadRequest, "id must be the position of an exhibition in the list"))
+		return
 	}
+	exhibition := all[index]
+	deprecated(w, "/api/exhibitions/"+exhibition.ID)
+	writeJSON(w, http.StatusOK, exhibition)
 }
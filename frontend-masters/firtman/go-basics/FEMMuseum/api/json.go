@@ -0,0 +1,82 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/httpcache"
+	"frontendmasters.com/go/museum/validation"
+)
+
+var validator = validation.New(nil)
+
+// UseValidator sets the validator for incoming exhibitions. Call it at
+// startup, before the server starts handling requests.
+func UseValidator(v *validation.Validator) {
+	validator = v
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// storeNotModified validates a cached response derived from the whole
+// store in lang, which is cheap: nothing has to be encoded to compare it.
+func storeNotModified(w http.ResponseWriter, r *http.Request, lang string) bool {
+	etag := data.Version()
+	if lang != "" {
+		etag += "-" + lang
+	}
+	return httpcache.NotModified(w, r, `"`+etag+`"`, data.LastModified())
+}
+
+// writeJSONWithETag encodes v up front so it can be validated by a hash
+// of its bytes.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeProblem(w, r, problem(http.StatusInternalServerError, "the response couldn't be encoded"))
+		return
+	}
+	sum := sha256.Sum256(body)
+	if httpcache.NotModified(w, r, `"`+hex.EncodeToString(sum[:8])+`"`, time.Time{}) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// writeStoreError writes the problem of an error coming from the data
+// package.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, storeProblem(err))
+}
+
+// storeProblem maps errors coming from the data package to a problem.
+func storeProblem(err error) Problem {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return problem(http.StatusNotFound, "there is no exhibition with this ID")
+	case errors.Is(err, data.ErrRevisionNotFound):
+		return problem(http.StatusNotFound, "the exhibition has no revision with this number")
+	case errors.Is(err, data.ErrSlugTaken):
+		p := invalid(validation.Errors{{Field: "Slug", Message: err.Error()}})
+		p.Status = http.StatusConflict
+		return p
+	default:
+		return problem(http.StatusInternalServerError, "the exhibition couldn't be saved")
+	}
+}
+
+// deprecated flags a legacy endpoint and points clients to its successor.
+func deprecated(w http.ResponseWriter, successor string) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+}
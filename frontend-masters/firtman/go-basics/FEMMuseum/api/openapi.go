@@ -0,0 +1,596 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"frontendmasters.com/go/museum/auth"
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/i18n"
+	"frontendmasters.com/go/museum/stats"
+	"frontendmasters.com/go/museum/tickets"
+	"frontendmasters.com/go/museum/transfer"
+	"frontendmasters.com/go/museum/webhooks"
+)
+
+// Router is what Register adds the routes to; *http.ServeMux is one.
+type Router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// operation documents a route. The schemas of body and result are
+// reflected from their types and json tags, so they follow the code.
+type operation struct {
+	summary    string
+	tag        string
+	role       auth.Role // the role needed, empty when public
+	query      []parameter
+	body       any  // the JSON request body, nil when there's none
+	upload     bool // the request is a multipart form with an "image" file
+	csv        bool // the body or, without one, the response may be CSV
+	status     int
+	result     any    // the JSON response, nil when there's none
+	media      string // the response media type when it isn't JSON
+	deprecated bool
+}
+
+type parameter struct {
+	name, description string
+	schema            map[string]any
+}
+
+var (
+	stringParam  = map[string]any{"type": "string"}
+	integerParam = map[string]any{"type": "integer"}
+	boolParam    = map[string]any{"type": "boolean"}
+	dateParam    = map[string]any{"type": "string", "format": "date"}
+)
+
+// langParam overrides Accept-Language. Exhibitions are sent as stored,
+// with English text, when neither is given.
+var langParam = parameter{"lang", "language tag like pt-BR, instead of Accept-Language", stringParam}
+
+func enumParam(values ...string) map[string]any {
+	return map[string]any{"type": "string", "enum": values}
+}
+
+// operations documents every route of Register, by pattern.
+var operations = map[string]operation{
+	"GET /api/exhibitions": {
+		summary: "List exhibitions, every one unless limit is set",
+		tag:     "exhibitions",
+		query: []parameter{
+			{"open", "only the exhibitions currently opened, or closed", boolParam},
+			{"color", "only the exhibitions of a palette color", stringParam},
+			{"sort", "title or -title", enumParam(data.SortTitle, data.SortTitleDesc)},
+			{"limit", "page size, at most " + strconv.Itoa(MaxPageSize), integerParam},
+			{"cursor", "from the Link header of the previous page", stringParam},
+			{"id", "deprecated: the position of one exhibition in the list", integerParam},
+			langParam,
+		},
+		status: http.StatusOK,
+		result: []data.Exhibition{},
+	},
+	"POST /api/exhibitions": {
+		summary: "Create an exhibition",
+		tag:     "exhibitions",
+		role:    auth.Editor,
+		body:    data.Exhibition{},
+		status:  http.StatusCreated,
+		result:  data.Exhibition{},
+	},
+	"GET /api/exhibitions.ics": {
+		summary: "The schedules of the exhibitions as an iCalendar feed",
+		tag:     "exhibitions",
+		query:   []parameter{langParam},
+		status:  http.StatusOK,
+		media:   "text/calendar",
+	},
+	"GET /api/exhibitions/events": {
+		summary: "A Server-Sent Events stream of created, updated and deleted exhibitions",
+		tag:     "exhibitions",
+		status:  http.StatusOK,
+		media:   "text/event-stream",
+	},
+	"GET /api/exhibitions/{id}": {
+		summary: "Read an exhibition",
+		tag:     "exhibitions",
+		query:   []parameter{langParam},
+		status:  http.StatusOK,
+		result:  data.Exhibition{},
+	},
+	"PUT /api/exhibitions/{id}": {
+		summary: "Replace an exhibition",
+		tag:     "exhibitions",
+		role:    auth.Editor,
+		body:    data.Exhibition{},
+		status:  http.StatusOK,
+		result:  data.Exhibition{},
+	},
+	"PATCH /api/exhibitions/{id}": {
+		summary: "Change some fields of an exhibition",
+		tag:     "exhibitions",
+		role:    auth.Editor,
+		body:    exhibitionPatch{},
+		status:  http.StatusOK,
+		result:  data.Exhibition{},
+	},
+	"DELETE /api/exhibitions/{id}": {
+		summary: "Delete an exhibition",
+		tag:     "exhibitions",
+		role:    auth.Editor,
+		status:  http.StatusNoContent,
+	},
+	"POST /api/exhibitions/{id}/image": {
+		summary: "Upload the image of an exhibition",
+		tag:     "images",
+		role:    auth.Editor,
+		upload:  true,
+		status:  http.StatusOK,
+		result:  data.Exhibition{},
+	},
+	"GET /api/exhibitions/{id}/revisions": {
+		summary: "List the revisions of an exhibition",
+		tag:     "revisions",
+		role:    auth.Viewer,
+		status:  http.StatusOK,
+		result:  []data.Revision{},
+	},
+	"GET /api/exhibitions/{id}/revisions/diff": {
+		summary: "The fields that changed between two revisions",
+		tag:     "revisions",
+		role:    auth.Viewer,
+		query: []parameter{
+			{"from", "defaults to the revision before to", integerParam},
+			{"to", "defaults to the latest revision", integerParam},
+		},
+		status: http.StatusOK,
+		result: revisionDiff{},
+	},
+	"GET /api/exhibitions/{id}/revisions/{n}": {
+		summary: "Read a revision",
+		tag:     "revisions",
+		role:    auth.Viewer,
+		status:  http.StatusOK,
+		result:  data.Revision{},
+	},
+	"POST /api/exhibitions/{id}/revisions/{n}/restore": {
+		summary: "Bring an exhibition back to a revision",
+		tag:     "revisions",
+		role:    auth.Editor,
+		status:  http.StatusOK,
+		result:  data.Exhibition{},
+	},
+	"GET /api/exhibitions/{id}/slots": {
+		summary: "The entry slots of a day with the places left",
+		tag:     "tickets",
+		query:   []parameter{{"date", "defaults to today in the museum's time zone", dateParam}},
+		status:  http.StatusOK,
+		result:  []tickets.Slot{},
+	},
+	"POST /api/exhibitions/{id}/holds": {
+		summary: "Hold places in a slot for a few minutes",
+		tag:     "tickets",
+		body:    holdRequest{},
+		status:  http.StatusCreated,
+		result:  tickets.Hold{},
+	},
+	"DELETE /api/holds/{id}": {
+		summary: "Give held places back",
+		tag:     "tickets",
+		status:  http.StatusNoContent,
+	},
+	"POST /api/holds/{id}/confirm": {
+		summary: "Turn a hold into a ticket",
+		tag:     "tickets",
+		body:    confirmRequest{},
+		status:  http.StatusCreated,
+		result:  tickets.Ticket{},
+	},
+	"GET /api/tickets/{code}": {
+		summary: "Read a ticket",
+		tag:     "tickets",
+		role:    auth.Viewer,
+		status:  http.StatusOK,
+		result:  tickets.Ticket{},
+	},
+	"POST /api/tickets/{code}/checkin": {
+		summary: "Let a ticket in, once",
+		tag:     "tickets",
+		role:    auth.Viewer,
+		status:  http.StatusOK,
+		result:  tickets.Ticket{},
+	},
+	"POST /api/images": {
+		summary: "Upload an image to use as an exhibition's Image",
+		tag:     "images",
+		role:    auth.Editor,
+		upload:  true,
+		status:  http.StatusCreated,
+		result:  uploadResponse{},
+	},
+	"GET /api/stats/exhibitions": {
+		summary: "The exhibitions viewed the most",
+		tag:     "stats",
+		role:    auth.Viewer,
+		query: []parameter{
+			{"granularity", "day reads daily buckets, hour the last hours", enumParam(string(stats.Daily), string(stats.Hourly))},
+			{"from", "first day, defaults to 7 days before to", dateParam},
+			{"to", "last day, defaults to today", dateParam},
+			{"hours", "with granularity=hour, 24 by default and at most 48", integerParam},
+			{"top", "how many are ranked, 10 by default and at most 100", integerParam},
+		},
+		status: http.StatusOK,
+		result: statsResponse{},
+	},
+	"GET /api/search": {
+		summary: "Search exhibitions",
+		tag:     "exhibitions",
+		query: []parameter{
+			{"q", "the words searched, required", stringParam},
+			{"limit", "at most " + strconv.Itoa(MaxPageSize), integerParam},
+			langParam,
+		},
+		status: http.StatusOK,
+		result: searchResponse{},
+	},
+	"GET /api/translations/missing": {
+		summary: "The exhibitions visitors would see partly in English",
+		tag:     "translations",
+		role:    auth.Editor,
+		query:   []parameter{{"lang", "comma separated language tags, every translated language by default", stringParam}},
+		status:  http.StatusOK,
+		result:  []i18n.Missing{},
+	},
+	"POST /api/admin/import": {
+		summary: "Import exhibitions from JSON or CSV",
+		tag:     "admin",
+		role:    auth.Admin,
+		query: []parameter{
+			{"match", "how records are matched to exhibitions", enumParam(transfer.MatchByTitle, transfer.MatchByID)},
+			{"overwrite", "replace the exhibitions that differ", boolParam},
+			{"dryRun", "only report what would happen", boolParam},
+		},
+		body:   []transfer.Record{},
+		csv:    true,
+		status: http.StatusOK,
+		result: transfer.Report{},
+	},
+	"GET /api/admin/export": {
+		summary: "Export every exhibition",
+		tag:     "admin",
+		role:    auth.Admin,
+		query:   []parameter{{"format", "", enumParam("json", "csv")}},
+		csv:     true,
+		status:  http.StatusOK,
+		result:  []data.Exhibition{},
+	},
+	"GET /api/webhooks": {
+		summary: "List the webhook subscriptions",
+		tag:     "webhooks",
+		role:    auth.Admin,
+		status:  http.StatusOK,
+		result:  []webhooks.Subscription{},
+	},
+	"POST /api/webhooks": {
+		summary: "Subscribe to exhibition events; the answer is the only one showing the secret",
+		tag:     "webhooks",
+		role:    auth.Admin,
+		body:    webhookRequest{},
+		status:  http.StatusCreated,
+		result:  webhooks.Subscription{},
+	},
+	"GET /api/webhooks/dead-letters": {
+		summary: "List the deliveries that failed every attempt",
+		tag:     "webhooks",
+		role:    auth.Admin,
+		status:  http.StatusOK,
+		result:  []webhooks.Delivery{},
+	},
+	"POST /api/webhooks/dead-letters/{id}/retry": {
+		summary: "Try a failed delivery again",
+		tag:     "webhooks",
+		role:    auth.Admin,
+		status:  http.StatusAccepted,
+		result:  webhooks.Delivery{},
+	},
+	"GET /api/webhooks/{id}": {
+		summary: "Read a webhook subscription",
+		tag:     "webhooks",
+		role:    auth.Admin,
+		status:  http.StatusOK,
+		result:  webhooks.Subscription{},
+	},
+	"DELETE /api/webhooks/{id}": {
+		summary: "Unsubscribe",
+		tag:     "webhooks",
+		role:    auth.Admin,
+		status:  http.StatusNoContent,
+	},
+	"GET /api/webhooks/{id}/deliveries": {
+		summary: "The recent deliveries to a subscription, newest first",
+		tag:     "webhooks",
+		role:    auth.Admin,
+		status:  http.StatusOK,
+		result:  []webhooks.Delivery{},
+	},
+	"POST /api/exhibitions/new": {
+		summary:    "Create an exhibition; use POST /api/exhibitions instead",
+		tag:        "exhibitions",
+		role:       auth.Editor,
+		body:       data.Exhibition{},
+		status:     http.StatusCreated,
+		media:      "text/plain",
+		deprecated: true,
+	},
+	"GET /api/openapi.json": {
+		summary: "This document",
+		tag:     "meta",
+		status:  http.StatusOK,
+		media:   "application/json",
+	},
+}
+
+// patterns records the patterns registered on it.
+type patterns []string
+
+func (p *patterns) HandleFunc(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+	*p = append(*p, pattern)
+}
+
+// Patterns returns the patterns Register adds, in order.
+func Patterns() []string {
+	var p patterns
+	Register(&p)
+	return p
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`)
+
+var (
+	specOnce sync.Once
+	spec     map[string]any
+)
+
+// Spec returns the OpenAPI 3.1 document of the routes of Register.
+// Routes without an entry in operations are left out.
+func Spec() map[string]any {
+	specOnce.Do(func() { spec = buildSpec() })
+	return spec
+}
+
+func buildSpec() map[string]any {
+	s := schemas{components: map[string]any{}, names: map[reflect.Type]string{}}
+	s.component(reflect.TypeFor[Problem]())
+	paths := map[string]map[string]any{}
+	tags := map[string]bool{}
+	for _, pattern := range Patterns() {
+		op, ok := operations[pattern]
+		if !ok {
+			continue
+		}
+		method, path, _ := strings.Cut(pattern, " ")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = s.operation(op, path)
+		tags[op.tag] = true
+	}
+	var tagList []map[string]any
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		tagList = append(tagList, map[string]any{"name": tag})
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "Museum API",
+			"version": "1",
+			"description": "Errors are RFC 7807 problem details. Writes need an API key or token " +
+				"with at least the role named in each operation: viewer, editor or admin.",
+		},
+		"tags":  tagList,
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": s.components,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// OpenAPI serves GET /api/openapi.json.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Spec())
+}
+
+func (s *schemas) operation(op operation, path string) map[string]any {
+	o := map[string]any{
+		"summary": op.summary,
+		"tags":    []string{op.tag},
+	}
+	if op.deprecated {
+		o["deprecated"] = true
+	}
+	var params []map[string]any
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{"name": match[1], "in": "path", "required": true, "schema": stringParam})
+	}
+	for _, p := range op.query {
+		param := map[string]any{"name": p.name, "in": "query", "schema": p.schema}
+		if p.description != "" {
+			param["description"] = p.description
+		}
+		params = append(params, param)
+	}
+	if params != nil {
+		o["parameters"] = params
+	}
+
+	switch {
+	case op.upload:
+		o["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{"multipart/form-data": map[string]any{"schema": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"image": map[string]any{"type": "string", "contentMediaType": "image/*"}},
+				"required":   []string{"image"},
+			}}},
+		}
+	case op.body != nil:
+		content := map[string]any{"application/json": map[string]any{"schema": s.of(reflect.TypeOf(op.body))}}
+		if op.csv {
+			content["text/csv"] = map[string]any{"schema": stringParam}
+		}
+		o["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+
+	success := map[string]any{"description": http.StatusText(op.status)}
+	content := map[string]any{}
+	if op.result != nil {
+		content["application/json"] = map[string]any{"schema": s.of(reflect.TypeOf(op.result))}
+	}
+	if op.media != "" {
+		content[op.media] = map[string]any{}
+	}
+	if op.csv && op.body == nil {
+		content["text/csv"] = map[string]any{"schema": stringParam}
+	}
+	if len(content) > 0 {
+		success["content"] = content
+	}
+	problems := map[string]any{"application/problem+json": map[string]any{"schema": s.of(reflect.TypeFor[Problem]())}}
+	responses := map[string]any{
+		strconv.Itoa(op.status): success,
+		"429":                   map[string]any{"description": "Over the rate limit; see Retry-After", "content": problems},
+		"default":               map[string]any{"description": "A problem", "content": problems},
+	}
+	if op.role != "" {
+		o["security"] = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+		o["x-role"] = string(op.role)
+		o["description"] = "Needs the " + string(op.role) + " role or above."
+		responses["401"] = map[string]any{"description": "No valid credentials", "content": problems}
+		responses["403"] = map[string]any{"description": "The credentials don't have the " + string(op.role) + " role", "content": problems}
+	}
+	o["responses"] = responses
+	return o
+}
+
+// schemas reflects JSON Schemas from Go types the way encoding/json
+// encodes them, keeping the structs in components.
+type schemas struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func (s *schemas) of(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		return map[string]any{"$ref": "#/components/schemas/" + s.component(t)}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	}
+	return map[string]any{} // any value
+}
+
+// component adds the schema of the struct t to the components and returns
+// its name: the type name, prefixed by its package when taken.
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := exported(t.Name())
+	if _, taken := s.components[name]; taken {
+		name = exported(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + name
+	}
+	s.names[t] = name
+	s.components[name] = nil // reserves the name while fields refer back to t
+	properties := map[string]any{}
+	var required []string
+	s.fields(t, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		slices.Sort(required)
+		schema["required"] = required
+	}
+	s.components[name] = schema
+	return name
+}
+
+// fields adds the fields of t, flattening embedded structs like
+// encoding/json does. Fields that may be left out aren't required.
+func (s *schemas) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.fields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+		optional := field.Type.Kind() == reflect.Pointer
+		for _, opt := range strings.Split(opts, ",") {
+			optional = optional || opt == "omitempty" || opt == "omitzero"
+		}
+		if !optional {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
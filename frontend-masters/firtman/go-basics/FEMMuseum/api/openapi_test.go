@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"frontendmasters.com/go/museum/auth"
+)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	paths := Spec()["paths"].(map[string]map[string]any)
+	registered := Patterns()
+	for _, pattern := range registered {
+		method, path, _ := strings.Cut(pattern, " ")
+		if _, ok := paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("%s is registered but missing from the OpenAPI document; add it to operations", pattern)
+		}
+	}
+	for pattern := range operations {
+		if !slices.Contains(registered, pattern) {
+			t.Errorf("%s is documented but not registered", pattern)
+		}
+	}
+}
+
+// TestDocumentedRoles checks the documented roles against the routes: the
+// protected ones turn away a client without credentials, the others don't.
+func TestDocumentedRoles(t *testing.T) {
+	a, err := auth.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseAuth(a)
+	defer UseAuth(nil)
+	mux := http.NewServeMux()
+	Register(mux)
+
+	// Canceled, so streams end right away.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, pattern := range Patterns() {
+		method, path, _ := strings.Cut(pattern, " ")
+		r := httptest.NewRequestWithContext(ctx, method, pathParam.ReplaceAllString(path, "1"), nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		protected := w.Code == http.StatusUnauthorized
+		if role := operations[pattern].role; protected != (role != "") {
+			t.Errorf("%s answered %d but is documented with role %q", pattern, w.Code, role)
+		}
+	}
+}
+
+func TestExhibitionSchema(t *testing.T) {
+	schemas := Spec()["components"].(map[string]any)["schemas"].(map[string]any)
+	exhibition := schemas["Exhibition"].(map[string]any)
+	properties := exhibition["properties"].(map[string]any)
+	for _, name := range []string{"ID", "Title", "CurrentlyOpened", "Schedule", "Entry"} {
+		if properties[name] == nil {
+			t.Errorf("Exhibition has no %s property", name)
+		}
+	}
+	required := exhibition["required"].([]string)
+	if !slices.Contains(required, "Title") || slices.Contains(required, "Schedule") {
+		t.Errorf("Exhibition requires %q", required)
+	}
+	if ref := properties["Schedule"].(map[string]any)["$ref"]; ref != "#/components/schemas/Schedule" {
+		t.Errorf("Schedule refers to %v", ref)
+	}
+	// Embedded structs are flattened like encoding/json does.
+	popular := schemas["PopularExhibition"].(map[string]any)["properties"].(map[string]any)
+	if popular["views"] == nil || popular["title"] == nil {
+		t.Errorf("PopularExhibition has %v", popular)
+	}
+}
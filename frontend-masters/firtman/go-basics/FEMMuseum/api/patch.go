@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// exhibitionPatch holds the fields a PATCH may change; nil means untouched.
+type exhibitionPatch struct {
+	Slug            *string
+	Title           *string
+	Description     *string
+	Image           *string
+	Color           *string
+	CurrentlyOpened *bool
+	Schedule        *data.Schedule
+	Entry           *data.TimedEntry
+	Translations    map[string]data.Translation // replaces them all; {} removes them
+}
+
+func (p exhibitionPatch) apply(e data.Exhibition) data.Exhibition {
+	if p.Slug != nil {
+		e.Slug = *p.Slug
+	}
+	if p.Title != nil {
+		e.Title = *p.Title
+	}
+	if p.Description != nil {
+		e.Description = *p.Description
+	}
+	if p.Image != nil {
+		e.Image = *p.Image
+	}
+	if p.Color != nil {
+		e.Color = *p.Color
+	}
+	if p.CurrentlyOpened != nil {
+		e.CurrentlyOpened = *p.CurrentlyOpened
+	}
+	if p.Schedule != nil {
+		e.Schedule = p.Schedule
+	}
+	if p.Entry != nil {
+		e.Entry = p.Entry
+	}
+	if p.Translations != nil {
+		e.Translations = p.Translations
+		if len(e.Translations) == 0 {
+			e.Translations = nil
+		}
+	}
+	return e
+}
+
+// Patch serves PATCH /api/exhibitions/{id} and only changes the fields sent.
+func Patch(w http.ResponseWriter, r *http.Request) {
+	var patch exhibitionPatch
+	if !decodeJSON(w, r, &patch) {
+		return
+	}
+	// The patch is applied to the exhibition as stored when it's written,
+	// so a concurrent write isn't undone.
+	var invalidErr error
+	updated, err := data.Modify(r.Context(), r.PathValue("id"), func(current data.Exhibition) (data.Exhibition, error) {
+		patched := patch.apply(current)
+		if invalidErr = validator.Exhibition(patched); invalidErr != nil {
+			return data.Exhibition{}, invalidErr
+		}
+		patched.Variants = variantsFor(patched.Image)
+		return patched, nil
+	})
+	if invalidErr != nil {
+		writeProblem(w, r, invalid(invalidErr))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -1,25 +1,47 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"frontendmasters.com/go/museum/data"
 )
 
+// Create serves POST /api/exhibitions and answers with the stored exhibition.
+func Create(w http.ResponseWriter, r *http.Request) {
+	created, ok := create(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Location", "/api/exhibitions/"+created.ID)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Post serves the deprecated POST /api/exhibitions/new.
 func Post(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost {
-		var exhibition data.Exhibition
-		err := json.NewDecoder(r.Body).Decode(&exhibition)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		} else {
-			data.Add(exhibition)
-			w.WriteHeader(http.StatusCreated)
-			w.Write([]byte("OK"))
-		}
-	} else {
-		http.Error(w, "Unsupported Method", http.StatusMethodNotAllowed)
+	created, ok := create(w, r)
+	if !ok {
+		return
+	}
+	deprecated(w, "/api/exhibitions")
+	w.Header().Set("Location", "/api/exhibitions/"+created.ID)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("OK"))
+}
+
+func create(w http.ResponseWriter, r *http.Request) (data.Exhibition, bool) {
+	var exhibition data.Exhibition
+	if !decodeJSON(w, r, &exhibition) {
+		return data.Exhibition{}, false
+	}
+	if err := validator.Exhibition(exhibition); err != nil {
+		writeProblem(w, r, invalid(err))
+		return data.Exhibition{}, false
+	}
+	exhibition.Variants = variantsFor(exhibition.Image)
+	created, err := data.Add(r.Context(), exhibition)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return data.Exhibition{}, false
 	}
+	return created, true
 }
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"frontendmasters.com/go/museum/transfer"
+	"frontendmasters.com/go/museum/validation"
+)
+
+// MaxBodyBytes bounds the size of a JSON request body.
+const MaxBodyBytes = 64 << 10
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   validation.Errors `json:"errors,omitempty"`
+	// Report is what an import did before failing.
+	Report *transfer.Report `json:"report,omitempty"`
+}
+
+const (
+	problemValidation = "/problems/validation-error"
+	problemMalformed  = "/problems/malformed-body"
+	problemTooLarge   = "/problems/body-too-large"
+	problemAuth       = "/problems/unauthenticated"
+	problemForbidden  = "/problems/forbidden"
+)
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.Path
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func problem(status int, detail string) Problem {
+	return Problem{Status: status, Detail: detail}
+}
+
+func invalid(err error) Problem {
+	p := Problem{
+		Type:   problemValidation,
+		Title:  "Your request has invalid fields",
+		Status: http.StatusUnprocessableEntity,
+	}
+	if errs, ok := err.(validation.Errors); ok {
+		p.Errors = errs
+	} else {
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// decodeJSON reads a size limited body holding exactly one JSON value with
+// only known fields into v. On failure it writes the problem and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil && decoder.More() {
+		err = errors.New("body must contain a single JSON object")
+	}
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	p := Problem{Type: problemMalformed, Title: "Your request body couldn't be read", Status: http.StatusBadRequest}
+	switch {
+	case errors.As(err, &tooLarge):
+		p = Problem{
+			Type:   problemTooLarge,
+			Title:  "Your request body is too large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("the body must not be larger than %d bytes", tooLarge.Limit),
+		}
+	case errors.As(err, &syntaxErr):
+		p.Detail = fmt.Sprintf("malformed JSON at byte %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		p.Errors = validation.Errors{{Field: typeErr.Field, Message: "must be a JSON " + typeErr.Type.Kind().String()}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		p.Errors = validation.Errors{{Field: field, Message: "is not a known field"}}
+	case errors.Is(err, io.EOF):
+		p.Detail = "the body is empty"
+	default:
+		p.Detail = err.Error()
+	}
+	writeProblem(w, r, p)
+	return false
+}
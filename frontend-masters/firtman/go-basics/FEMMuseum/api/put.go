@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// Put serves PUT /api/exhibitions/{id} and replaces the whole exhibition.
+func Put(w http.ResponseWriter, r *http.Request) {
+	var exhibition data.Exhibition
+	if !decodeJSON(w, r, &exhibition) {
+		return
+	}
+	exhibition.ID = r.PathValue("id")
+	if err := validator.Exhibition(exhibition); err != nil {
+		writeProblem(w, r, invalid(err))
+		return
+	}
+	exhibition.Variants = variantsFor(exhibition.Image)
+	updated, err := data.Update(r.Context(), exhibition)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
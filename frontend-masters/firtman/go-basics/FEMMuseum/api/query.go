@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/validation"
+)
+
+const MaxPageSize = 100
+
+// parseListQuery reads ?open=, ?color=, ?sort=, ?limit= and ?cursor= into
+// a data.Query.
+func parseListQuery(values url.Values) (data.Query, error) {
+	var q data.Query
+	var errs validation.Errors
+
+	if open := values.Get("open"); open != "" {
+		value, err := strconv.ParseBool(open)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: "open", Message: "must be true or false"})
+		} else {
+			q.Open = &value
+		}
+	}
+	if color := values.Get("color"); color != "" {
+		if !slices.Contains(validation.Palette, color) {
+			errs = append(errs, validation.FieldError{Field: "color", Message: "must be one of " + strings.Join(validation.Palette, ", ")})
+		}
+		q.Color = color
+	}
+	switch sort := values.Get("sort"); sort {
+	case "", data.SortTitle, data.SortTitleDesc:
+		q.Sort = sort
+	default:
+		errs = append(errs, validation.FieldError{Field: "sort", Message: "must be title or -title"})
+	}
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > MaxPageSize {
+			errs = append(errs, validation.FieldError{Field: "limit", Message: "must be a number between 1 and " + strconv.Itoa(MaxPageSize)})
+		}
+		q.Limit = value
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := data.DecodeCursor(cursor)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: "cursor", Message: "is not a cursor returned by this API"})
+		}
+		q.Offset = offset
+	}
+
+	if len(errs) > 0 {
+		return q, errs
+	}
+	return q, nil
+}
+
+// writePageHeaders sets X-Total-Count and the next/prev Link header.
+func writePageHeaders(w http.ResponseWriter, r *http.Request, page data.Page) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	var links []string
+	for _, link := range []struct{ rel, cursor string }{{"next", page.Next}, {"prev", page.Prev}} {
+		if link.cursor == "" {
+			continue
+		}
+		u := *r.URL
+		values := u.Query()
+		values.Set("cursor", link.cursor)
+		u.RawQuery = values.Encode()
+		links = append(links, "<"+u.RequestURI()+`>; rel="`+link.rel+`"`)
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// Revisions serves GET /api/exhibitions/{id}/revisions, oldest first.
+func Revisions(w http.ResponseWriter, r *http.Request) {
+	list, err := data.Revisions(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Revision serves GET /api/exhibitions/{id}/revisions/{n}.
+func Revision(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil {
+		writeProblem(w, r, problem(http.StatusBadRequest, "the revision must be a number"))
+		return
+	}
+	rev, err := data.GetRevision(r.PathValue("id"), n)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rev)
+}
+
+type revisionDiff struct {
+	From    int                `json:"from"`
+	To      int                `json:"to"`
+	Changes []data.FieldChange `json:"changes"`
+}
+
+// DiffRevisions serves GET /api/exhibitions/{id}/revisions/diff?from=&to=,
+// the fields that changed between two revisions. to defaults to the latest
+// revision and from to the one before to.
+func DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	list, err := data.Revisions(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	to, ok := revisionNumber(w, r, "to", len(list), len(list))
+	if !ok {
+		return
+	}
+	from, ok := revisionNumber(w, r, "from", max(to-1, 1), len(list))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, revisionDiff{
+		From:    from,
+		To:      to,
+		Changes: data.Diff(list[from-1].Exhibition, list[to-1].Exhibition),
+	})
+}
+
+func revisionNumber(w http.ResponseWriter, r *http.Request, name string, fallback, latest int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > latest {
+		writeProblem(w, r, problem(http.StatusBadRequest, name+" must be a revision between 1 and "+strconv.Itoa(latest)))
+		return 0, false
+	}
+	return n, true
+}
+
+// RestoreRevision serves POST /api/exhibitions/{id}/revisions/{n}/restore.
+// The exhibition goes back to revision n, which is recorded as a new
+// revision.
+func RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil {
+		writeProblem(w, r, problem(http.StatusBadRequest, "the revision must be a number"))
+		return
+	}
+	rev, err := data.GetRevision(id, n)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	// The gallery may have changed since, so the old version must still
+	// be valid today.
+	if err := validator.Exhibition(rev.Exhibition); err != nil {
+		writeProblem(w, r, invalid(err))
+		return
+	}
+	restored, err := data.Restore(r.Context(), id, n)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, restored)
+}
@@ -0,0 +1,47 @@
+package api
+
+import "frontendmasters.com/go/museum/auth"
+
+// Register adds the API routes to mux. Reads are public except for the
+// revision history; writes need an editor, and moving the whole store in or
+// out or managing webhooks an admin. Visitors book tickets without a key,
+// while the front desk checks them in with a viewer key. Every route needs
+// an entry in operations to be documented in the OpenAPI document.
+func Register(mux Router) {
+	mux.HandleFunc("GET /api/exhibitions", List)
+	mux.HandleFunc("POST /api/exhibitions", require(auth.Editor, Create))
+	mux.HandleFunc("GET /api/exhibitions.ics", Calendar)
+	mux.HandleFunc("GET /api/exhibitions/events", Events)
+	mux.HandleFunc("GET /api/exhibitions/{id}", Get)
+	mux.HandleFunc("PUT /api/exhibitions/{id}", require(auth.Editor, Put))
+	mux.HandleFunc("PATCH /api/exhibitions/{id}", require(auth.Editor, Patch))
+	mux.HandleFunc("DELETE /api/exhibitions/{id}", require(auth.Editor, Delete))
+	mux.HandleFunc("POST /api/exhibitions/{id}/image", require(auth.Editor, UploadImage))
+	mux.HandleFunc("GET /api/exhibitions/{id}/revisions", require(auth.Viewer, Revisions))
+	mux.HandleFunc("GET /api/exhibitions/{id}/revisions/diff", require(auth.Viewer, DiffRevisions))
+	mux.HandleFunc("GET /api/exhibitions/{id}/revisions/{n}", require(auth.Viewer, Revision))
+	mux.HandleFunc("POST /api/exhibitions/{id}/revisions/{n}/restore", require(auth.Editor, RestoreRevision))
+	mux.HandleFunc("GET /api/exhibitions/{id}/slots", Slots)
+	mux.HandleFunc("POST /api/exhibitions/{id}/holds", HoldSlot)
+	mux.HandleFunc("DELETE /api/holds/{id}", ReleaseHold)
+	mux.HandleFunc("POST /api/holds/{id}/confirm", ConfirmHold)
+	mux.HandleFunc("GET /api/tickets/{code}", require(auth.Viewer, Ticket))
+	mux.HandleFunc("POST /api/tickets/{code}/checkin", require(auth.Viewer, CheckIn))
+	mux.HandleFunc("POST /api/images", require(auth.Editor, Upload))
+	mux.HandleFunc("GET /api/stats/exhibitions", require(auth.Viewer, Stats))
+	mux.HandleFunc("GET /api/search", Search)
+	mux.HandleFunc("GET /api/translations/missing", require(auth.Editor, MissingTranslations))
+	mux.HandleFunc("GET /api/openapi.json", OpenAPI)
+	mux.HandleFunc("POST /api/admin/import", require(auth.Admin, Import))
+	mux.HandleFunc("GET /api/admin/export", require(auth.Admin, Export))
+	mux.HandleFunc("GET /api/webhooks", require(auth.Admin, ListWebhooks))
+	mux.HandleFunc("POST /api/webhooks", require(auth.Admin, CreateWebhook))
+	mux.HandleFunc("GET /api/webhooks/dead-letters", require(auth.Admin, DeadLetters))
+	mux.HandleFunc("POST /api/webhooks/dead-letters/{id}/retry", require(auth.Admin, RetryDeadLetter))
+	mux.HandleFunc("GET /api/webhooks/{id}", require(auth.Admin, GetWebhook))
+	mux.HandleFunc("DELETE /api/webhooks/{id}", require(auth.Admin, DeleteWebhook))
+	mux.HandleFunc("GET /api/webhooks/{id}/deliveries", require(auth.Admin, WebhookDeliveries))
+
+	// Deprecated aliases kept for existing clients
+	mux.HandleFunc("POST /api/exhibitions/new", require(auth.Editor, Post))
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/i18n"
+	"frontendmasters.com/go/museum/search"
+)
+
+const defaultSearchLimit = 10
+
+var searchIndex = search.NewIndex()
+
+// UseSearchIndex sets the index behind /api/search. Call it at startup,
+// before the server starts handling requests.
+func UseSearchIndex(idx *search.Index) {
+	searchIndex = idx
+}
+
+type searchResult struct {
+	Exhibition data.Exhibition   `json:"exhibition"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type searchResponse struct {
+	Query   string         `json:"query"`
+	Results []searchResult `json:"results"`
+}
+
+// Search serves GET /api/search?q=. Highlights are HTML with the matched
+// words wrapped in <mark>; only the English text is searched.
+func Search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeProblem(w, r, problem(http.StatusBadRequest, "the q parameter is required"))
+		return
+	}
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxPageSize {
+			writeProblem(w, r, problem(http.StatusBadRequest, "limit must be a number between 1 and "+strconv.Itoa(MaxPageSize)))
+			return
+		}
+		limit = n
+	}
+
+	lang, ok := language(w, r, i18n.Languages(data.GetAll()))
+	if !ok {
+		return
+	}
+	if storeNotModified(w, r, lang) {
+		return
+	}
+	response := searchResponse{Query: q, Results: []searchResult{}}
+	for _, result := range searchIndex.Search(q, limit) {
+		exhibition, err := data.Get(result.ID)
+		if err != nil {
+			// Deleted between searching and reading it
+			continue
+		}
+		if lang != "" {
+			exhibition = i18n.Localize(exhibition, lang)
+		}
+		response.Results = append(response.Results, searchResult{
+			Exhibition: exhibition,
+			Score:      result.Score,
+			Highlights: result.Highlights,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/stats"
+)
+
+var recorder *stats.Recorder
+
+// UseStats sets where exhibition views are counted. Call it at startup,
+// before the server starts handling requests.
+func UseStats(r *stats.Recorder) {
+	recorder = r
+}
+
+const (
+	defaultStatsDays  = 7
+	defaultStatsHours = 24
+	defaultTop        = 10
+	maxTop            = 100
+)
+
+type popularExhibition struct {
+	stats.Ranked
+	Title string `json:"title,omitempty"` // empty once deleted
+	Slug  string `json:"slug,omitempty"`
+}
+
+type statsResponse struct {
+	Granularity stats.Granularity   `json:"granularity"`
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	Exhibitions []popularExhibition `json:"exhibitions"`
+}
+
+// Stats serves GET /api/stats/exhibitions, the exhibitions viewed the most.
+// ?granularity=day (the default) reads daily buckets between ?from and ?to,
+// dates defaulting to the last 7 days; ?granularity=hour reads the last
+// ?hours, 24 by default and at most 48. ?top picks how many are ranked.
+func Stats(w http.ResponseWriter, r *http.Request) {
+	if recorder == nil {
+		writeProblem(w, r, problem(http.StatusServiceUnavailable, "view counting is not enabled"))
+		return
+	}
+	query := r.URL.Query()
+	q := stats.Query{Granularity: stats.Granularity(query.Get("granularity")), Top: defaultTop}
+	if value := query.Get("top"); value != "" {
+		top, err := strconv.Atoi(value)
+		if err != nil || top < 1 || top > maxTop {
+			writeProblem(w, r, problem(http.StatusBadRequest, "top must be between 1 and 100"))
+			return
+		}
+		q.Top = top
+	}
+
+	now := time.Now()
+	switch q.Granularity {
+	case stats.Hourly:
+		hours := defaultStatsHours
+		if value := query.Get("hours"); value != "" {
+			var err error
+			hours, err = strconv.Atoi(value)
+			if err != nil || hours < 1 || hours > int(stats.HourlyRetention/time.Hour) {
+				writeProblem(w, r, problem(http.StatusBadRequest, "hours must be between 1 and 48"))
+				return
+			}
+		}
+		q.To = now.UTC().Truncate(time.Hour).Add(time.Hour)
+		q.From = q.To.Add(-time.Duration(hours) * time.Hour)
+	case stats.Daily, "":
+		q.Granularity = stats.Daily
+		today := now.In(data.Location()).Format(data.DateFormat)
+		from, to := query.Get("from"), query.Get("to")
+		if to == "" {
+			to = today
+		}
+		end, err := time.ParseInLocation(data.DateFormat, to, data.Location())
+		if err != nil {
+			writeProblem(w, r, problem(http.StatusBadRequest, "to must be a date like 2024-05-31"))
+			return
+		}
+		if from == "" {
+			from = end.AddDate(0, 0, 1-defaultStatsDays).Format(data.DateFormat)
+		}
+		start, err := time.ParseInLocation(data.DateFormat, from, data.Location())
+		if err != nil || start.After(end) {
+			writeProblem(w, r, problem(http.StatusBadRequest, "from must be a date like 2024-05-31, not after to"))
+			return
+		}
+		q.From, q.To = start, end.AddDate(0, 0, 1)
+	default:
+		writeProblem(w, r, problem(http.StatusBadRequest, "granularity must be hour or day"))
+		return
+	}
+
+	ranked := recorder.Popular(q)
+	list := make([]popularExhibition, len(ranked))
+	for i, item := range ranked {
+		list[i] = popularExhibition{Ranked: item}
+		if e, err := data.Get(item.ID); err == nil {
+			list[i].Title, list[i].Slug = e.Title, e.Slug
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, statsResponse{
+		Granularity: q.Granularity,
+		From:        q.From.UTC(),
+		To:          q.To.UTC(),
+		Exhibitions: list,
+	})
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/middleware"
+	"frontendmasters.com/go/museum/tickets"
+	"frontendmasters.com/go/museum/validation"
+)
+
+var booker = tickets.NewBooker()
+
+// trustedProxies are the proxies whose X-Forwarded-For names who holds
+// places.
+var trustedProxies []netip.Prefix
+
+// UseTickets sets where tickets are booked. Call it at startup, before the
+// server starts handling requests.
+func UseTickets(b *tickets.Booker) {
+	booker = b
+}
+
+// UseTrustedProxies sets the proxies whose X-Forwarded-For is believed
+// when telling apart the clients holding places. Call it at startup,
+// before the server starts handling requests.
+func UseTrustedProxies(trusted []netip.Prefix) {
+	trustedProxies = trusted
+}
+
+const (
+	problemSoldOut     = "/problems/sold-out"
+	problemTooManyHeld = "/problems/too-many-held"
+)
+
+// holder names the client of r the way the rate limiter does: by its
+// credentials, or else by its IP.
+func holder(r *http.Request) string {
+	if client, ok := Identify(r); ok {
+		return "client " + client
+	}
+	return "ip " + middleware.ClientIP(r, trustedProxies).String()
+}
+
+// Slots serves GET /api/exhibitions/{id}/slots?date=2024-05-31, the entry
+// slots of a day with the places left. The date defaults to today.
+func Slots(w http.ResponseWriter, r *http.Request) {
+	e, err := data.Get(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	day := r.URL.Query().Get("date")
+	if day == "" {
+		day = time.Now().In(data.Location()).Format(data.DateFormat)
+	}
+	if _, err := time.Parse(data.DateFormat, day); err != nil {
+		writeProblem(w, r, problem(http.StatusBadRequest, "date must look like 2024-05-31"))
+		return
+	}
+	slots := booker.Availability(e, day)
+	if slots == nil {
+		slots = []tickets.Slot{}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, slots)
+}
+
+type holdRequest struct {
+	Start    time.Time `json:"start"`
+	Quantity int       `json:"quantity,omitempty"` // 1 when left out
+}
+
+type confirmRequest struct {
+	Name string `json:"name"`
+}
+
+// HoldSlot serves POST /api/exhibitions/{id}/holds with the start of a
+// slot and a quantity. The places are kept for a few minutes, until the
+// hold is confirmed or released. A client only holds a few places at
+// once, and each hold counts against its write budget.
+func HoldSlot(w http.ResponseWriter, r *http.Request) {
+	var body holdRequest
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	e, err := data.Get(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if body.Quantity == 0 {
+		body.Quantity = 1
+	}
+	hold, err := booker.Hold(holder(r), e, body.Start, body.Quantity)
+	if err != nil {
+		writeTicketError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/api/holds/"+hold.ID)
+	writeJSON(w, http.StatusCreated, hold)
+}
+
+// ReleaseHold serves DELETE /api/holds/{id}.
+func ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	if err := booker.Release(r.PathValue("id")); err != nil {
+		writeTicketError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ConfirmHold serves POST /api/holds/{id}/confirm with the name the
+// ticket is for, and answers with the ticket.
+func ConfirmHold(w http.ResponseWriter, r *http.Request) {
+	var body confirmRequest
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len(body.Name) > 200 {
+		writeProblem(w, r, invalid(validation.Errors{{Field: "name", Message: "is required and at most 200 bytes long"}}))
+		return
+	}
+	ticket, err := booker.Confirm(r.PathValue("id"), body.Name)
+	if err != nil {
+		writeTicketError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/api/tickets/"+ticket.Code)
+	writeJSON(w, http.StatusCreated, ticket)
+}
+
+// Ticket serves GET /api/tickets/{code}.
+func Ticket(w http.ResponseWriter, r *http.Request) {
+	ticket, err := booker.Ticket(r.PathValue("code"))
+	if err != nil {
+		writeTicketError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ticket)
+}
+
+// CheckIn serves POST /api/tickets/{code}/checkin. A ticket is only let in
+// once; scanning it again answers 409.
+func CheckIn(w http.ResponseWriter, r *http.Request) {
+	ticket, err := booker.CheckIn(r.PathValue("code"))
+	if errors.Is(err, tickets.ErrAlreadyCheckedIn) {
+		writeProblem(w, r, problem(http.StatusConflict, "the ticket was already checked in at "+ticket.CheckedIn.Format(time.RFC3339)))
+		return
+	}
+	if err != nil {
+		writeTicketError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ticket)
+}
+
+func writeTicketError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, tickets.ErrNoSlot):
+		writeProblem(w, r, invalid(validation.Errors{{Field: "start", Message: "is not the start of one of the exhibition's slots"}}))
+	case errors.Is(err, tickets.ErrSlotStarted):
+		writeProblem(w, r, invalid(validation.Errors{{Field: "start", Message: "is in the past"}}))
+	case errors.Is(err, tickets.ErrBadQuantity):
+		writeProblem(w, r, invalid(validation.Errors{{Field: "quantity", Message: "must be between 1 and 10"}}))
+	case errors.Is(err, tickets.ErrSoldOut):
+		writeProblem(w, r, Problem{
+			Type:   problemSoldOut,
+			Title:  "The slot doesn't have enough places left",
+			Status: http.StatusConflict,
+		})
+	case errors.Is(err, tickets.ErrTooManyHeld):
+		writeProblem(w, r, Problem{
+			Type:   problemTooManyHeld,
+			Title:  "You already hold too many places",
+			Status: http.StatusConflict,
+			Detail: "confirm or release your holds first",
+		})
+	case errors.Is(err, tickets.ErrHoldNotFound):
+		writeProblem(w, r, problem(http.StatusNotFound, "there is no hold with this ID"))
+	case errors.Is(err, tickets.ErrHoldExpired):
+		writeProblem(w, r, problem(http.StatusGone, "the hold expired and its places were given back"))
+	case errors.Is(err, tickets.ErrTicketNotFound):
+		writeProblem(w, r, problem(http.StatusNotFound, "there is no ticket with this code"))
+	default:
+		writeProblem(w, r, problem(http.StatusInternalServerError, "the ticket couldn't be saved"))
+	}
+}
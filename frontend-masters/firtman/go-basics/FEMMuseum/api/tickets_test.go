@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/tickets"
+)
+
+func TestClientsHoldFewPlacesAtOnce(t *testing.T) {
+	data.Use(data.NewMemoryRepository())
+	defer data.Use(data.NewMemoryRepository())
+	b := tickets.NewBooker()
+	b.MaxHeld = 3
+	UseTickets(b)
+	defer UseTickets(tickets.NewBooker())
+
+	var hours []data.OpeningHours
+	for _, day := range data.Weekdays {
+		hours = append(hours, data.OpeningHours{Day: day, Open: "09:00", Close: "17:00"})
+	}
+	e, err := data.Add(context.Background(), data.Exhibition{
+		Title:    "Amber",
+		Schedule: &data.Schedule{Hours: hours},
+		Entry:    &data.TimedEntry{SlotMinutes: 60, Capacity: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := time.Now().In(data.Location()).AddDate(0, 0, 1).Format(data.DateFormat)
+	start, _ := json.Marshal(tickets.Slots(e, tomorrow)[0].Start)
+
+	hold := func(remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/exhibitions/"+e.ID+"/holds", strings.NewReader(`{"start": `+string(start)+`, "quantity": 2}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.RemoteAddr = remote
+		r.SetPathValue("id", e.ID)
+		w := httptest.NewRecorder()
+		HoldSlot(w, r)
+		return w
+	}
+	if w := hold("192.0.2.1:1000"); w.Code != http.StatusCreated {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if w := hold("192.0.2.1:2000"); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), problemTooManyHeld) {
+		t.Errorf("holding more from the same address got %d: %s", w.Code, w.Body)
+	}
+	if w := hold("192.0.2.2:1000"); w.Code != http.StatusCreated {
+		t.Errorf("another address got %d: %s", w.Code, w.Body)
+	}
+}
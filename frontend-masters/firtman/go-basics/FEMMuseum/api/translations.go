@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/i18n"
+)
+
+// language negotiates the language r is answered in out of available and
+// sets Content-Language. It's empty when r doesn't ask for one, so clients
+// that don't care get exhibitions as stored and can write them back as they
+// are. A malformed ?lang= is answered with a problem and false.
+func language(w http.ResponseWriter, r *http.Request, available []string) (string, bool) {
+	w.Header().Add("Vary", "Accept-Language")
+	if !i18n.Requested(r) {
+		return "", true
+	}
+	if query := r.URL.Query(); query.Has("lang") {
+		if _, ok := i18n.Canonical(query.Get("lang")); !ok {
+			writeProblem(w, r, problem(http.StatusBadRequest, "lang must be a language tag like pt-BR"))
+			return "", false
+		}
+	}
+	lang := i18n.Negotiate(r, available)
+	w.Header().Set("Content-Language", lang)
+	return lang, true
+}
+
+func localize(list []data.Exhibition, lang string) []data.Exhibition {
+	if lang == "" {
+		return list
+	}
+	localized := make([]data.Exhibition, len(list))
+	for i, e := range list {
+		localized[i] = i18n.Localize(e, lang)
+	}
+	return localized
+}
+
+// MissingTranslations serves GET /api/translations/missing, the exhibitions
+// visitors would see partly in English. ?lang= picks the languages, comma
+// separated; by default it's every language some exhibition has.
+func MissingTranslations(w http.ResponseWriter, r *http.Request) {
+	all := data.GetAll()
+	languages := i18n.Languages(all)
+	if value := r.URL.Query().Get("lang"); value != "" {
+		languages = nil
+		for _, tag := range strings.Split(value, ",") {
+			canonical, ok := i18n.Canonical(strings.TrimSpace(tag))
+			if !ok {
+				writeProblem(w, r, problem(http.StatusBadRequest, "lang must be language tags like pt-BR, comma separated"))
+				return
+			}
+			languages = append(languages, canonical)
+		}
+	}
+	writeJSON(w, http.StatusOK, i18n.MissingTranslations(all, languages))
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/gallery"
+)
+
+var images *gallery.Store
+
+// UseGallery sets where uploaded images are stored. Uploads are refused
+// until it's called at startup.
+func UseGallery(s *gallery.Store) {
+	images = s
+}
+
+type uploadResponse struct {
+	Image    string
+	Variants *data.ImageVariants
+}
+
+// Upload serves POST /api/images. The image is sent as the "image" field of
+// a multipart form, and the returned name can be used as Exhibition.Image.
+func Upload(w http.ResponseWriter, r *http.Request) {
+	name, ok := saveUpload(w, r)
+	if !ok {
+		return
+	}
+	variants := images.Variants(name)
+	if variants == nil {
+		// A copy went missing between saving and now.
+		writeProblem(w, r, problem(http.StatusInternalServerError, "the image couldn't be stored"))
+		return
+	}
+	w.Header().Set("Location", variants.Original)
+	writeJSON(w, http.StatusCreated, uploadResponse{Image: name, Variants: variants})
+}
+
+// UploadImage serves POST /api/exhibitions/{id}/image and replaces the
+// exhibition's image with the uploaded one.
+func UploadImage(w http.ResponseWriter, r *http.Request) {
+	if _, err := data.Get(r.PathValue("id")); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	name, ok := saveUpload(w, r)
+	if !ok {
+		return
+	}
+	// The exhibition is read again, since it may have changed during the
+	// upload.
+	updated, err := data.Modify(r.Context(), r.PathValue("id"), func(e data.Exhibition) (data.Exhibition, error) {
+		e.Image = name
+		e.Variants = images.Variants(name)
+		return e, nil
+	})
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func saveUpload(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if images == nil {
+		writeProblem(w, r, problem(http.StatusServiceUnavailable, "image uploads are not enabled"))
+		return "", false
+	}
+	// Leave some room for the multipart framing around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, gallery.MaxUploadBytes+64<<10)
+	file, _, err := r.FormFile("image")
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeProblem(w, r, Problem{Type: problemTooLarge, Status: http.StatusRequestEntityTooLarge, Detail: "images must not be larger than 10 MB"})
+		return "", false
+	case err != nil:
+		writeProblem(w, r, problem(http.StatusBadRequest, "send the image as the \"image\" field of a multipart/form-data body"))
+		return "", false
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, gallery.MaxUploadBytes+1))
+	if err != nil {
+		writeProblem(w, r, problem(http.StatusBadRequest, "the image couldn't be read"))
+		return "", false
+	}
+	if len(content) > gallery.MaxUploadBytes {
+		writeProblem(w, r, Problem{Type: problemTooLarge, Status: http.StatusRequestEntityTooLarge, Detail: "images must not be larger than 10 MB"})
+		return "", false
+	}
+
+	name, err := images.Save(content)
+	switch {
+	case errors.Is(err, gallery.ErrNotAnImage):
+		writeProblem(w, r, problem(http.StatusUnsupportedMediaType, err.Error()))
+		return "", false
+	case errors.Is(err, gallery.ErrImageTooLarge):
+		writeProblem(w, r, Problem{Type: problemTooLarge, Status: http.StatusRequestEntityTooLarge, Detail: err.Error()})
+		return "", false
+	case err != nil:
+		writeProblem(w, r, problem(http.StatusInternalServerError, "the image couldn't be stored"))
+		return "", false
+	}
+	return name, true
+}
+
+// variantsFor returns the generated copies of an uploaded image; they are
+// derived from Image and never taken from the request.
+func variantsFor(image string) *data.ImageVariants {
+	if images == nil {
+		return nil
+	}
+	return images.Variants(image)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"frontendmasters.com/go/museum/gallery"
+)
+
+func upload(t *testing.T, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("image", "rock.png")
+	part.Write(content)
+	form.Close()
+	r := httptest.NewRequest("POST", "/api/images", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	Upload(w, r)
+	return w
+}
+
+func TestUploadAgainAfterLosingACopy(t *testing.T) {
+	dir := t.TempDir()
+	UseGallery(gallery.NewStore(dir, "/gallery"))
+	defer UseGallery(nil)
+	var content bytes.Buffer
+	png.Encode(&content, image.NewGray(image.Rect(0, 0, 40, 30)))
+
+	w := upload(t, content.Bytes())
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var uploaded uploadResponse
+	json.Unmarshal(w.Body.Bytes(), &uploaded)
+	thumbnail := filepath.Join(dir, strings.TrimPrefix(uploaded.Variants.Thumbnail, "/gallery/uploads/"))
+	if err := os.Remove(thumbnail); err != nil {
+		t.Fatal(err)
+	}
+
+	w = upload(t, content.Bytes())
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != uploaded.Variants.Original {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(thumbnail); err != nil {
+		t.Errorf("the thumbnail wasn't generated again: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"frontendmasters.com/go/museum/validation"
+	"frontendmasters.com/go/museum/webhooks"
+)
+
+var dispatcher *webhooks.Dispatcher
+
+// UseWebhooks sets the dispatcher behind /api/webhooks. Call it at startup,
+// before the server starts handling requests.
+func UseWebhooks(d *webhooks.Dispatcher) {
+	dispatcher = d
+}
+
+// webhooksEnabled writes a problem and returns false when there's no
+// dispatcher.
+func webhooksEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if dispatcher == nil {
+		writeProblem(w, r, problem(http.StatusServiceUnavailable, "webhooks are not enabled"))
+		return false
+	}
+	return true
+}
+
+// ListWebhooks serves GET /api/webhooks.
+func ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, dispatcher.Subscriptions())
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // every event when empty
+	Secret string   `json:"secret,omitempty"` // generated when empty
+}
+
+// CreateWebhook serves POST /api/webhooks. The answer is the only one
+// showing the secret.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled(w, r) {
+		return
+	}
+	var body webhookRequest
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	s, err := dispatcher.Subscribe(webhooks.Subscription{URL: body.URL, Events: body.Events, Secret: body.Secret})
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/api/webhooks/"+s.ID)
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// GetWebhook serves GET /api/webhooks/{id}.
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled(w, r) {
+		return
+	}
+	s, err := dispatcher.Subscription(r.PathValue("id"))
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// DeleteWebhook serves DELETE /api/webhooks/{id}. Deliveries not sent yet
+// are dropped.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled(w, r) {
+		return
+	}
+	if err := dispatcher.Unsubscribe(r.PathValue("id")); err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WebhookDeliveries serves GET /api/webhooks/{id}/deliveries, the recent
+// deliveries to a subscription with every attempt and its response code.
+func WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled(w, r) {
+		return
+	}
+	list, err := dispatcher.Deliveries(r.PathValue("id"))
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// DeadLetters serves GET /api/webhooks/dead-letters, the deliveries that
+// ran out of attempts.
+func DeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, dispatcher.DeadLetters())
+}
+
+// RetryDeadLetter serves POST /api/webhooks/dead-letters/{id}/retry.
+func RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled(w, r) {
+		return
+	}
+	delivery, err := dispatcher.Retry(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, r, problem(http.StatusNotFound, "there is no dead letter with this ID"))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, delivery)
+}
+
+func writeWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, webhooks.ErrNotFound):
+		writeProblem(w, r, problem(http.StatusNotFound, "there is no webhook with this ID"))
+	case errors.As(err, new(validation.Errors)):
+		writeProblem(w, r, invalid(err))
+	default:
+		writeProblem(w, r, problem(http.StatusInternalServerError, "the webhook couldn't be saved"))
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNoCredentials = errors.New("auth: no credentials")
+	ErrInvalidKey    = errors.New("auth: invalid API key")
+)
+
+// Authenticator checks the credentials of requests: an API key or a
+// token, sent as "Authorization: Bearer <credential>". API keys may also be
+// sent in X-API-Key.
+type Authenticator struct {
+	Keys   *KeyStore
+	Tokens *Signer
+	Audit  *AuditLog
+}
+
+// Open sets up an Authenticator keeping its files in dir: keys.json,
+// token.key and audit.log.
+func Open(dir string) (*Authenticator, error) {
+	keys, err := OpenKeys(filepath.Join(dir, "keys.json"))
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := LoadSigner(filepath.Join(dir, "token.key"))
+	if err != nil {
+		return nil, err
+	}
+	audit, err := OpenAudit(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{Keys: keys, Tokens: tokens, Audit: audit}, nil
+}
+
+// Authenticate returns who sent r.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		scheme, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			return Principal{}, ErrNoCredentials
+		}
+		credential = strings.TrimSpace(value)
+	}
+	return a.Verify(credential)
+}
+
+// Verify returns who credential, an API key or a token, belongs to.
+func (a *Authenticator) Verify(credential string) (Principal, error) {
+	if credential == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	if strings.HasPrefix(credential, KeyPrefix) {
+		key, ok := a.Keys.Verify(credential)
+		if !ok {
+			return Principal{}, ErrInvalidKey
+		}
+		return Principal{Subject: key.Name, Role: key.Role, Method: "key"}, nil
+	}
+	claims, err := a.Tokens.Verify(credential)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{Subject: claims.Subject, Role: claims.Role, Method: "token"}, nil
+}
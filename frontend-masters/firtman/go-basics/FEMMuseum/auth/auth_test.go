@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	signer := NewSigner([]byte(strings.Repeat("k", 32)))
+	token, _, err := signer.Issue("alice", Editor, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := signer.Verify(token)
+	if err != nil || claims.Subject != "alice" || claims.Role != Editor {
+		t.Fatalf("got %+v, %v", claims, err)
+	}
+
+	header, rest, _ := strings.Cut(token, ".")
+	payload, signature, _ := strings.Cut(rest, ".")
+	for name, forged := range map[string]string{
+		"other secret": mustIssue(t, NewSigner([]byte(strings.Repeat("x", 32)))),
+		"alg none":     "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." + payload + ".",
+		"changed role": header + "." + strings.ToUpper(payload[:4]) + payload[4:] + "." + signature,
+		"garbage":      "not.a.token",
+	} {
+		if _, err := signer.Verify(forged); err != ErrInvalidToken {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := signer.Verify(token); err != ErrExpiredToken {
+		t.Errorf("got %v for an expired token", err)
+	}
+}
+
+func mustIssue(t *testing.T, s *Signer) string {
+	token, _, err := s.Issue("mallory", Admin, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestKeysAreReloadedWhenRevokedElsewhere(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	server, _ := OpenKeys(path)
+	admin, _ := OpenKeys(path)
+
+	secret, key, err := admin.Create("ci", Editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Verify(secret); ok {
+		t.Error("a key was looked up in the file before a reload")
+	}
+	if err := server.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := server.Verify(secret); !ok || got.Role != Editor {
+		t.Fatalf("a new key wasn't accepted: %+v, %v", got, ok)
+	}
+	if _, ok := server.Verify(secret[:len(secret)-1] + "0"); ok {
+		t.Error("a wrong secret was accepted")
+	}
+	if err := admin.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Verify(secret); ok {
+		t.Error("a revoked key is still accepted")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Audit.Close()
+	secret, _, _ := a.Keys.Create("ci", Admin)
+	token, _, _ := a.Tokens.Issue("bob", Viewer, time.Hour)
+
+	for _, c := range []struct {
+		header, value string
+		want          Principal
+		fails         bool
+	}{
+		{"Authorization", "Bearer " + secret, Principal{"ci", Admin, "key"}, false},
+		{"X-API-Key", secret, Principal{"ci", Admin, "key"}, false},
+		{"Authorization", "bearer " + token, Principal{"bob", Viewer, "token"}, false},
+		{"Authorization", "Basic Ym9iOnB3", Principal{}, true},
+		{"Authorization", "Bearer mk_nothing_here", Principal{}, true},
+	} {
+		r := httptest.NewRequest("POST", "/api/exhibitions", nil)
+		r.Header.Set(c.header, c.value)
+		got, err := a.Authenticate(r)
+		if (err != nil) != c.fails || got != c.want {
+			t.Errorf("%s: %.20s…: got %+v, %v", c.header, c.value, got, err)
+		}
+	}
+	if !Admin.Allows(Editor) || Viewer.Allows(Editor) || Role("owner").Allows(Viewer) {
+		t.Error("roles don't nest")
+	}
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyPrefix starts every API key, so keys can be told apart from tokens
+// and spotted when they leak.
+const KeyPrefix = "mk_"
+
+var ErrKeyNotFound = errors.New("auth: no API key with this ID")
+
+// Key is a stored API key. Only the SHA-256 of the secret is kept: keys are
+// long random strings, so a slow hash would add nothing.
+type Key struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Role    Role      `json:"role"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+// DefaultReloadInterval is how often a running server looks for keys
+// created or revoked by the admin command.
+const DefaultReloadInterval = 10 * time.Second
+
+// KeyStore keeps API keys in a JSON file. The file is reread when it
+// changes, by Reload or before Create, Revoke and List, so keys created or
+// revoked by the admin command take effect on a running server.
+type KeyStore struct {
+	path string
+
+	mu      sync.Mutex
+	keys    []Key
+	modTime time.Time
+	size    int64
+}
+
+// OpenKeys reads the keys in path. A missing file holds no keys.
+func OpenKeys(path string) (*KeyStore, error) {
+	s := &KeyStore{path: path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload rereads the file when it changed.
+func (s *KeyStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload()
+}
+
+// ReloadEvery calls Reload every interval until stop is called. Lookups
+// then never touch the file.
+func (s *KeyStore) ReloadEvery(interval time.Duration, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// Keep serving the keys we have when the file can't be read
+				// right now.
+				if err := s.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// reload rereads the file when it changed. Callers hold mu.
+func (s *KeyStore) reload() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.keys, s.modTime, s.size = nil, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var keys []Key
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	s.keys, s.modTime, s.size = keys, info.ModTime(), info.Size()
+	return nil
+}
+
+func (s *KeyStore) save() error {
+	content, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.modTime = time.Time{} // reread our own write on the next reload
+	return nil
+}
+
+// Create stores a new key and returns its secret, which is shown once and
+// can't be recovered afterwards.
+func (s *KeyStore) Create(name string, role Role) (string, Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return "", Key{}, err
+	}
+	id, err := randomHex(4)
+	if err != nil {
+		return "", Key{}, err
+	}
+	random, err := randomHex(32)
+	if err != nil {
+		return "", Key{}, err
+	}
+	secret := KeyPrefix + id + "_" + random
+	key := Key{ID: id, Name: name, Role: role, Hash: hash(secret), Created: time.Now().UTC()}
+	s.keys = append(s.keys, key)
+	if err := s.save(); err != nil {
+		return "", Key{}, err
+	}
+	return secret, key, nil
+}
+
+// Revoke deletes the key with id.
+func (s *KeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(s.keys, func(k Key) bool { return k.ID == id })
+	if i < 0 {
+		return ErrKeyNotFound
+	}
+	s.keys = slices.Delete(s.keys, i, i+1)
+	return s.save()
+}
+
+func (s *KeyStore) List() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.keys), nil
+}
+
+// Verify returns the key secret belongs to.
+func (s *KeyStore) Verify(secret string) (Key, bool) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(secret, KeyPrefix), "_")
+	if !ok || !strings.HasPrefix(secret, KeyPrefix) {
+		return Key{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := hash(secret)
+	for _, k := range s.keys {
+		if k.ID == id && subtle.ConstantTimeCompare([]byte(k.Hash), []byte(sum)) == 1 {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,55 @@
+// Package auth authenticates API clients with API keys and signed bearer
+// tokens, and records the requests it turns away.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Role is what a client may do. Each role can do everything the roles
+// before it can.
+type Role string
+
+const (
+	Viewer Role = "viewer" // read only, and check tickets in at the door
+	Editor Role = "editor" // create, change and delete exhibitions
+	Admin  Role = "admin"  // also import and export the whole store
+)
+
+var roles = []Role{Viewer, Editor, Admin}
+
+// ParseRole returns the role named s.
+func ParseRole(s string) (Role, error) {
+	if !slices.Contains(roles, Role(s)) {
+		return "", fmt.Errorf("unknown role %q, want viewer, editor or admin", s)
+	}
+	return Role(s), nil
+}
+
+// Allows reports whether r may do what required may.
+func (r Role) Allows(required Role) bool {
+	have := slices.Index(roles, r)
+	return have >= 0 && have >= slices.Index(roles, required)
+}
+
+// Principal is the client behind an authenticated request.
+type Principal struct {
+	Subject string // key name or token subject
+	Role    Role
+	Method  string // "key" or "token"
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns who made the request of ctx, if it was
+// authenticated.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("auth: invalid token")
+	ErrExpiredToken = errors.New("auth: token expired")
+)
+
+// Claims are the fields of a bearer token.
+type Claims struct {
+	ID        string `json:"jti"`
+	Subject   string `json:"sub"`
+	Role      Role   `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer issues and checks HS256 JSON Web Tokens.
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret, now: time.Now}
+}
+
+// LoadSigner reads the signing secret from path, creating a random one the
+// first time.
+func LoadSigner(path string) (*Signer, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		var secret string
+		if secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		// O_EXCL so two processes starting together agree on one secret.
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			return LoadSigner(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if _, err := f.WriteString(secret + "\n"); err != nil {
+			return nil, err
+		}
+		content = []byte(secret)
+	} else if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(secret) < 32 {
+		return nil, fmt.Errorf("%s must hold at least 32 hex encoded bytes", path)
+	}
+	return NewSigner(secret), nil
+}
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Issue returns a token for subject with role, valid for ttl.
+func (s *Signer) Issue(subject string, role Role, ttl time.Duration) (string, Claims, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	now := s.now()
+	claims := Claims{
+		ID:        id,
+		Subject:   subject,
+		Role:      role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.sign(unsigned), claims, nil
+}
+
+// Verify checks the signature and expiry of token and returns its claims.
+// Only HS256 is accepted, whatever the header says.
+func (s *Signer) Verify(token string) (Claims, error) {
+	header, rest, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	payload, signature, ok := strings.Cut(rest, ".")
+	if !ok || header != tokenHeader {
+		return Claims{}, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(header+"."+payload))) {
+		return Claims{}, ErrInvalidToken
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(decoded, &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if _, err := ParseRole(string(claims.Role)); err != nil || claims.Subject == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if s.now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrExpiredToken
+	}
+	return claims, nil
+}
+
+func (s *Signer) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is how the museum server is set up. Every setting can be given as
+// a flag or as the environment variable next to it; flags win.
+type Config struct {
+	Addr string // MUSEUM_ADDR
+	// StaticDir and TemplateDir replace the site and templates built into
+	// the binary, to work on them without rebuilding.
+	StaticDir   string // MUSEUM_STATIC_DIR
+	TemplateDir string // MUSEUM_TEMPLATE_DIR
+	// UploadDir holds uploaded images, served under /gallery/uploads/.
+	// It defaults to gallery/uploads in StaticDir, or ./uploads.
+	UploadDir string // MUSEUM_UPLOAD_DIR
+	DataFile  string // MUSEUM_DATA_FILE, in memory only when empty
+	Dev       bool   // MUSEUM_DEV
+	// AuthDir holds the API keys, the token signing secret and the audit
+	// log of rejected requests.
+	AuthDir string // MUSEUM_AUTH_DIR
+	// Timezone is where exhibition schedules are read, like "Europe/Paris".
+	Timezone string // MUSEUM_TIMEZONE
+
+	// ImportFiles are merged into the store at startup, matched by title.
+	ImportFiles []string // MUSEUM_IMPORT, comma separated
+	// DataJSON is a file regenerated from the store, for hosting the static
+	// frontend elsewhere; nothing is written when it's empty. The server
+	// itself answers /gallery/data.json from the store.
+	DataJSON string // MUSEUM_DATA_JSON
+
+	// CORSOrigins may call /api/ from a browser; "*" allows any.
+	CORSOrigins []string // MUSEUM_CORS_ORIGINS, comma separated
+
+	// ReadsPerMinute and WritesPerMinute are the budgets of every API
+	// client, counted by API key or by address; zero lifts the limit.
+	ReadsPerMinute  int // MUSEUM_READS_PER_MINUTE
+	WritesPerMinute int // MUSEUM_WRITES_PER_MINUTE
+	// TrustedProxies are the addresses or CIDR prefixes of the proxies in
+	// front of the server, whose X-Forwarded-For tells the client address.
+	TrustedProxies []string // MUSEUM_TRUSTED_PROXIES, comma separated
+
+	ReadHeaderTimeout time.Duration // MUSEUM_READ_HEADER_TIMEOUT
+	ReadTimeout       time.Duration // MUSEUM_READ_TIMEOUT
+	WriteTimeout      time.Duration // MUSEUM_WRITE_TIMEOUT
+	IdleTimeout       time.Duration // MUSEUM_IDLE_TIMEOUT
+	ShutdownTimeout   time.Duration // MUSEUM_SHUTDOWN_TIMEOUT
+}
+
+func Default() Config {
+	return Config{
+		Addr:              ":3333",
+		AuthDir:           "./.auth",
+		Timezone:          "UTC",
+		ReadsPerMinute:    600,
+		WritesPerMinute:   60,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   15 * time.Second,
+	}
+}
+
+// Load reads the configuration from args (without the program name) and
+// the environment, looked up through getenv.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	c := Default()
+	env := envReader{getenv: getenv}
+	env.string("MUSEUM_ADDR", &c.Addr)
+	env.string("MUSEUM_STATIC_DIR", &c.StaticDir)
+	env.string("MUSEUM_TEMPLATE_DIR", &c.TemplateDir)
+	env.string("MUSEUM_UPLOAD_DIR", &c.UploadDir)
+	env.string("MUSEUM_DATA_FILE", &c.DataFile)
+	env.bool("MUSEUM_DEV", &c.Dev)
+	env.string("MUSEUM_AUTH_DIR", &c.AuthDir)
+	env.string("MUSEUM_TIMEZONE", &c.Timezone)
+	env.list("MUSEUM_IMPORT", &c.ImportFiles)
+	env.string("MUSEUM_DATA_JSON", &c.DataJSON)
+	env.list("MUSEUM_CORS_ORIGINS", &c.CORSOrigins)
+	env.int("MUSEUM_READS_PER_MINUTE", &c.ReadsPerMinute)
+	env.int("MUSEUM_WRITES_PER_MINUTE", &c.WritesPerMinute)
+	env.list("MUSEUM_TRUSTED_PROXIES", &c.TrustedProxies)
+	env.duration("MUSEUM_READ_HEADER_TIMEOUT", &c.ReadHeaderTimeout)
+	env.duration("MUSEUM_READ_TIMEOUT", &c.ReadTimeout)
+	env.duration("MUSEUM_WRITE_TIMEOUT", &c.WriteTimeout)
+	env.duration("MUSEUM_IDLE_TIMEOUT", &c.IdleTimeout)
+	env.duration("MUSEUM_SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	if env.err != nil {
+		return Config{}, env.err
+	}
+
+	flags := flag.NewFlagSet("museum", flag.ContinueOnError)
+	flags.StringVar(&c.Addr, "addr", c.Addr, "address to listen on")
+	flags.StringVar(&c.StaticDir, "static", c.StaticDir, "directory with the static site (the built-in one when empty)")
+	flags.StringVar(&c.TemplateDir, "templates", c.TemplateDir, "directory with the page templates (the built-in ones when empty)")
+	flags.StringVar(&c.UploadDir, "uploads", c.UploadDir, "directory for uploaded images")
+	flags.StringVar(&c.DataFile, "data", c.DataFile, "file to persist exhibitions to (in memory only when empty)")
+	flags.BoolVar(&c.Dev, "dev", c.Dev, "reload templates on every request")
+	flags.StringVar(&c.AuthDir, "auth-dir", c.AuthDir, "directory with the API keys, token secret and audit log")
+	flags.StringVar(&c.Timezone, "timezone", c.Timezone, "IANA time zone exhibition schedules are in")
+	flags.Func("import", "comma separated JSON or CSV files to merge into the store at startup", func(value string) error {
+		c.ImportFiles = splitList(value)
+		return nil
+	})
+	flags.StringVar(&c.DataJSON, "data-json", c.DataJSON, "JSON export of the store kept up to date for the static frontend (disabled when empty)")
+	flags.Func("cors-origins", "comma separated origins allowed to call the API from a browser", func(value string) error {
+		c.CORSOrigins = splitList(value)
+		return nil
+	})
+	flags.IntVar(&c.ReadsPerMinute, "reads-per-minute", c.ReadsPerMinute, "API reads allowed per client and minute (0 for no limit)")
+	flags.IntVar(&c.WritesPerMinute, "writes-per-minute", c.WritesPerMinute, "API writes allowed per client and minute (0 for no limit)")
+	flags.Func("trusted-proxies", "comma separated addresses or CIDR prefixes of proxies whose X-Forwarded-For is believed", func(value string) error {
+		c.TrustedProxies = splitList(value)
+		return nil
+	})
+	flags.DurationVar(&c.ReadHeaderTimeout, "read-header-timeout", c.ReadHeaderTimeout, "time allowed to read request headers")
+	flags.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "time allowed to read a whole request")
+	flags.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "time allowed to write a response")
+	flags.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "how long idle keep-alive connections stay open")
+	flags.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long to wait for in-flight requests when stopping")
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if c.UploadDir == "" {
+		c.UploadDir = "./uploads"
+		if c.StaticDir != "" {
+			c.UploadDir = filepath.Join(c.StaticDir, "gallery", "uploads")
+		}
+	}
+	return c, nil
+}
+
+// envReader overrides defaults with environment variables and remembers
+// the first malformed one.
+type envReader struct {
+	getenv func(string) string
+	err    error
+}
+
+func (e *envReader) string(name string, target *string) {
+	if value := e.getenv(name); value != "" {
+		*target = value
+	}
+}
+
+func (e *envReader) list(name string, target *[]string) {
+	if value := e.getenv(name); value != "" {
+		*target = splitList(value)
+	}
+}
+
+func (e *envReader) bool(name string, target *bool) {
+	value := e.getenv(name)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("%s: %q is not a boolean", name, value)
+	}
+	*target = parsed
+}
+
+func (e *envReader) int(name string, target *int) {
+	value := e.getenv(name)
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if (err != nil || parsed < 0) && e.err == nil {
+		e.err = fmt.Errorf("%s: %q is not a positive number", name, value)
+	}
+	*target = parsed
+}
+
+func (e *envReader) duration(name string, target *time.Duration) {
+	value := e.getenv(name)
+	if value == "" {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("%s: %q is not a duration like 30s", name, value)
+	}
+	*target = parsed
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
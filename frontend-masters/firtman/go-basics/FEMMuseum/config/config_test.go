@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlagsOverrideEnvironment(t *testing.T) {
+	env := map[string]string{
+		"MUSEUM_ADDR":         ":8080",
+		"MUSEUM_DATA_FILE":    "museum.json",
+		"MUSEUM_READ_TIMEOUT": "10s",
+	}
+	c, err := Load([]string{"-addr", ":9090"}, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != ":9090" || c.DataFile != "museum.json" || c.ReadTimeout != 10*time.Second {
+		t.Errorf("got %+v", c)
+	}
+	if c.StaticDir != Default().StaticDir {
+		t.Errorf("got static dir %q, want the default", c.StaticDir)
+	}
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	Created ChangeType = "created"
+	Updated ChangeType = "updated"
+	Deleted ChangeType = "deleted"
+)
+
+// Change describes one successful write to the store. For deletions
+// Exhibition holds the exhibition as it was before being removed.
+type Change struct {
+	Type       ChangeType
+	Exhibition Exhibition
+	Author     string // who made the change, when known
+	Time       time.Time
+}
+
+type authorKey struct{}
+
+// WithAuthor returns a context crediting the writes made with it to author.
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+func AuthorFrom(ctx context.Context) string {
+	author, _ := ctx.Value(authorKey{}).(string)
+	return author
+}
+
+var (
+	// writeMu serializes writes so subscribers see changes in the same order
+	// the store applied them.
+	writeMu     sync.Mutex
+	subscribers []func(Change)
+	subMu       sync.RWMutex
+)
+
+// Subscribe calls fn after every successful Add, Update and Delete. fn runs
+// while writes are blocked, so it must be quick and must not write itself.
+func Subscribe(fn func(Change)) {
+	subMu.Lock()
+	defer subMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+var (
+	version      atomic.Uint64
+	lastModified atomic.Int64 // unix nanoseconds
+	// epoch tells this process's versions apart from the ones before a
+	// restart, since versions start counting from zero again.
+	epoch = newEpoch()
+)
+
+func init() {
+	lastModified.Store(time.Now().UnixNano())
+}
+
+func newEpoch() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Version identifies the current state of the store. It changes on every
+// write, and when a schedule opens or closes an exhibition, so it can be
+// used to validate caches of anything derived from it.
+func Version() string {
+	v := epoch + "." + strconv.FormatUint(version.Load(), 10)
+	if state := scheduleState(); state != "" {
+		return v + "." + state
+	}
+	return v
+}
+
+var (
+	scheduleMu sync.Mutex
+	// openedState is the scheduleState last seen.
+	openedState string
+)
+
+// scheduleState hashes which of the scheduled exhibitions are open, and is
+// empty when none has a schedule. Seeing it change counts as a write for
+// LastModified, since CurrentlyOpened changed without one.
+func scheduleState() string {
+	var opened []string
+	scheduled := false
+	for _, e := range GetAll() {
+		if e.Schedule == nil {
+			continue
+		}
+		scheduled = true
+		if e.CurrentlyOpened {
+			opened = append(opened, e.ID)
+		}
+	}
+	state := ""
+	if scheduled {
+		h := fnv.New32a()
+		for _, id := range opened {
+			h.Write([]byte(id))
+		}
+		state = strconv.FormatUint(uint64(h.Sum32()), 36)
+	}
+
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+	if state != openedState {
+		openedState = state
+		// Not known exactly when it happened, but not after now.
+		t := now().UnixNano()
+		for old := lastModified.Load(); t > old && !lastModified.CompareAndSwap(old, t); old = lastModified.Load() {
+		}
+	}
+	return state
+}
+
+// LastModified is when the store was last written to, or a schedule opened
+// or closed an exhibition, or when the process started if neither happened
+// since.
+func LastModified() time.Time {
+	scheduleState()
+	return time.Unix(0, lastModified.Load())
+}
+
+// notify records a write and tells the subscribers about it.
+func notify(c Change) {
+	version.Add(1)
+	lastModified.Store(c.Time.UnixNano())
+
+	subMu.RLock()
+	defer subMu.RUnlock()
+	for _, fn := range subscribers {
+		fn(c)
+	}
+}
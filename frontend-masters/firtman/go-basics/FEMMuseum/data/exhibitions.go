@@ -1,48 +1,157 @@
 package data
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
 type Exhibition struct {
+	ID              string
+	Slug            string
 	Title           string
 	Description     string
 	Image           string
 	Color           string
-	CurrentlyOpened bool
-}
-
-var list = []Exhibition{
-	{
-		Title:           "Life in Ancient Greek",
-		Description:     "Uncover the world of ancient Greece through the sculptures, tools, and jewelry found in ruins from over 2000 years ago that have been unearthed through modern science and technology.",
-		Image:           "ancient-greece.png",
-		Color:           "red",
-		CurrentlyOpened: true,
-	},
-	{
-		Title:           "Aristotle: Life and Legacy",
-		Description:     "Explore the life and legacy of the great philosopher Aristotle, one of the most influential thinkers of all time. Through rare artifacts and ancient texts, learn about his ideas on ethics, politics, and metaphysics that have shaped the world for centuries.",
-		Image:           "aristotle.png",
-		Color:           "blue",
-		CurrentlyOpened: false,
-	},
-	{
-		Title:           "Chameleon: Colorful Adaptations",
-		Description:     "Discover the amazing world of chameleons and their incredible ability to change color. Through interactive displays and live chameleon exhibits, learn about the science behind their color changing and how they use it to communicate and camouflage in their environments.",
-		Image:           "colorful-adaptations.png",
-		Color:           "green",
-		CurrentlyOpened: true,
-	},
-	{
-		Title:           "Sea Monsters: Myth and Reality",
-		Description:     "Dive into the world of sea monsters and explore the myths and legends that have captured our imaginations for centuries. Through fossils, ancient maps, and interactive displays, discover the truth behind the stories and learn about the real-life creatures that inhabit our oceans.",
-		Image:           "sea-monsters.png",
-		Color:           "purple",
-		CurrentlyOpened: false,
-	},
-}
-
-func Add(e Exhibition) {
-	list = append(list, e)
+	CurrentlyOpened bool           // computed when there's a Schedule
+	Schedule        *Schedule      `json:",omitempty"`
+	Entry           *TimedEntry    `json:",omitempty"`
+	Variants        *ImageVariants `json:",omitempty"`
+	// Translations of Title and Description, by language tag like "pt-BR".
+	// The fields themselves are in English.
+	Translations map[string]Translation `json:",omitempty"`
+}
+
+// Translation is the text of an exhibition in another language. An empty
+// field falls back to a more general language, and in the end to English.
+type Translation struct {
+	Title       string `json:",omitempty"`
+	Description string `json:",omitempty"`
+}
+
+// ImageVariants are the URLs of the copies generated for an uploaded Image.
+type ImageVariants struct {
+	Original  string
+	Thumbnail string
+	Medium    string
+}
+
+var (
+	ErrNotFound  = errors.New("exhibition not found")
+	ErrSlugTaken = errors.New("slug already used by another exhibition")
+)
+
+// repo is the store behind the package functions. It defaults to memory and
+// is swapped with Use at startup, before the server starts handling requests.
+var repo Repository = NewMemoryRepository()
+
+func Use(r Repository) {
+	repo = r
+	version.Add(1)
+}
+
+// Add stores e under a newly generated ID and returns it as stored. The
+// author in ctx, if any, is credited with the revision.
+func Add(ctx context.Context, e Exhibition) (Exhibition, error) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	created, err := repo.Add(e)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	created = withStatus(created, now())
+	c := newChange(ctx, Created, created)
+	recordChange(c, nil, 0)
+	notify(c)
+	return created, nil
+}
+
+func Get(id string) (Exhibition, error) {
+	e, err := repo.Get(id)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	return withStatus(e, now()), nil
+}
+
+func GetBySlug(slug string) (Exhibition, error) {
+	for _, e := range GetAll() {
+		if e.Slug == slug {
+			return e, nil
+		}
+	}
+	return Exhibition{}, ErrNotFound
 }
 
 func GetAll() []Exhibition {
-	return list
+	return withStatuses(repo.GetAll())
+}
+
+// Update replaces the exhibition with e.ID. An empty Slug keeps the current one.
+func Update(ctx context.Context, e Exhibition) (Exhibition, error) {
+	return update(ctx, e, 0)
+}
+
+// Modify replaces the exhibition with id by what fn makes of it, with no
+// other write in between, so changes made from the current exhibition
+// aren't lost to a concurrent one. An error from fn is returned as is and
+// nothing is written. fn must not write through this package.
+func Modify(ctx context.Context, id string, fn func(Exhibition) (Exhibition, error)) (Exhibition, error) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	previous, err := Get(id)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	e, err := fn(previous)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	e.ID = id
+	return replace(ctx, previous, e, 0)
+}
+
+// update is Update recording that the write restores revision restores,
+// when it's not 0.
+func update(ctx context.Context, e Exhibition, restores int) (Exhibition, error) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	previous, err := Get(e.ID)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	return replace(ctx, previous, e, restores)
+}
+
+// replace writes e over previous. writeMu must be held.
+func replace(ctx context.Context, previous, e Exhibition, restores int) (Exhibition, error) {
+	updated, err := repo.Update(e)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	updated = withStatus(updated, now())
+	c := newChange(ctx, Updated, updated)
+	recordChange(c, &previous, restores)
+	notify(c)
+	return updated, nil
+}
+
+func Delete(ctx context.Context, id string) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	deleted, err := Get(id)
+	if err != nil {
+		return err
+	}
+	if err := repo.Delete(id); err != nil {
+		return err
+	}
+	c := newChange(ctx, Deleted, deleted)
+	recordChange(c, &deleted, 0)
+	notify(c)
+	return nil
+}
+
+func newChange(ctx context.Context, t ChangeType, e Exhibition) Change {
+	return Change{Type: t, Exhibition: e, Author: AuthorFrom(ctx), Time: time.Now().UTC()}
 }
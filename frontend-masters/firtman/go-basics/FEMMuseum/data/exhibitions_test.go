@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestModifyLosesNoConcurrentChanges(t *testing.T) {
+	Use(NewMemoryRepository())
+	defer Use(NewMemoryRepository())
+	ctx := context.Background()
+	e, err := Add(ctx, Exhibition{Title: "Amber"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Modify(ctx, e.ID, func(current Exhibition) (Exhibition, error) {
+				current.Description += "x"
+				return current, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := Get(e.ID); got.Description != strings.Repeat("x", 50) {
+		t.Errorf("got %d changes, want 50", len(got.Description))
+	}
+
+	refused := errors.New("refused")
+	if _, err := Modify(ctx, e.ID, func(Exhibition) (Exhibition, error) { return Exhibition{}, refused }); err != refused {
+		t.Errorf("got %v, want the error of fn", err)
+	}
+	if _, err := Modify(ctx, "missing", func(e Exhibition) (Exhibition, error) { return e, nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestLastModifiedFollowsSchedules(t *testing.T) {
+	Use(NewMemoryRepository())
+	defer Use(NewMemoryRepository())
+	defer func() { now = time.Now }()
+	// Writes are timed by the wall clock, so the schedule is ahead of it.
+	before := time.Now().UTC().AddDate(1, 0, 0)
+	opening := time.Date(before.Year(), before.Month(), before.Day()+1, 9, 0, 0, 0, time.UTC)
+	now = func() time.Time { return before }
+	Add(context.Background(), Exhibition{Title: "Amber", Schedule: &Schedule{Opens: opening.Format(DateFormat)}})
+	version, modified := Version(), LastModified()
+
+	now = func() time.Time { return opening }
+	if Version() == version {
+		t.Error("the version didn't change when the exhibition opened")
+	}
+	if got := LastModified(); !got.After(modified) || !got.Equal(opening) {
+		t.Errorf("got Last-Modified %v, want %v when it opened", got, opening)
+	}
+}
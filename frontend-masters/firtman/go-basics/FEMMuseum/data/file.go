@@ -0,0 +1,237 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+const defaultSnapshotEvery = 100
+
+// FileRepository keeps exhibitions in memory and makes every write durable
+// in an append-only journal (path + ".journal"). Every SnapshotEvery writes
+// the whole store is saved to path and the journal is truncated.
+type FileRepository struct {
+	SnapshotEvery int
+
+	mu      sync.Mutex
+	path    string
+	journal *os.File
+	seq     uint64 // sequence of the last applied write
+	pending int    // journal entries written since the last snapshot
+	mem     *MemoryRepository
+}
+
+const (
+	opAdd    = "add"
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+type journalEntry struct {
+	Seq        uint64     `json:"seq"`
+	Op         string     `json:"op"`
+	Exhibition Exhibition `json:"exhibition"`
+}
+
+type snapshot struct {
+	Seq         uint64       `json:"seq"`
+	Exhibitions []Exhibition `json:"exhibitions"`
+}
+
+// OpenFile loads the snapshot at path, replays the journal on top of it and
+// keeps the journal open for appending.
+func OpenFile(path string) (*FileRepository, error) {
+	f := &FileRepository{
+		SnapshotEvery: defaultSnapshotEvery,
+		path:          path,
+		mem:           NewMemoryRepository(),
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	journal, err := os.OpenFile(f.journalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	f.journal = journal
+	// Stores written before exhibitions had IDs get them now. They must be
+	// snapshotted right away, otherwise the next replay would generate
+	// different ones.
+	if f.mem.assignMissingIDs() {
+		if err := f.writeSnapshot(); err != nil {
+			journal.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *FileRepository) journalPath() string {
+	return f.path + ".journal"
+}
+
+func (f *FileRepository) load() error {
+	var snap snapshot
+	content, err := os.ReadFile(f.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &snap); err != nil {
+			return fmt.Errorf("reading snapshot %s: %w", f.path, err)
+		}
+	}
+	f.seq = snap.Seq
+	f.mem.replace(snap.Exhibitions)
+
+	if err := f.replay(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *FileRepository) replay() error {
+	return ReadJournal(f.journalPath(), func(entry journalEntry, line int) error {
+		// Entries already folded into the snapshot are skipped; this covers
+		// a crash between writing the snapshot and truncating the journal.
+		if entry.Seq <= f.seq {
+			return nil
+		}
+		if err := f.mem.apply(entry.Op, entry.Exhibition); err != nil {
+			return fmt.Errorf("journal %s line %d: %w", f.journalPath(), line, err)
+		}
+		f.seq = entry.Seq
+		f.pending++
+		return nil
+	})
+}
+
+func (f *FileRepository) Add(e Exhibition) (Exhibition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mem.mu.RLock()
+	e = f.mem.prepareAdd(e)
+	f.mem.mu.RUnlock()
+	return e, f.write(opAdd, e)
+}
+
+func (f *FileRepository) Get(id string) (Exhibition, error) {
+	return f.mem.Get(id)
+}
+
+func (f *FileRepository) GetAll() []Exhibition {
+	return f.mem.GetAll()
+}
+
+func (f *FileRepository) Update(e Exhibition) (Exhibition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mem.mu.RLock()
+	e, err := f.mem.prepareUpdate(e)
+	f.mem.mu.RUnlock()
+	if err != nil {
+		return Exhibition{}, err
+	}
+	return e, f.write(opPut, e)
+}
+
+func (f *FileRepository) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.mem.Get(id); err != nil {
+		return err
+	}
+	return f.write(opDelete, Exhibition{ID: id})
+}
+
+// write journals a prepared operation, applies it in memory and snapshots
+// when enough entries piled up. Callers hold f.mu, which makes them the only
+// writer between preparing and applying.
+func (f *FileRepository) write(op string, e Exhibition) error {
+	if err := f.append(op, e); err != nil {
+		return err
+	}
+	if err := f.mem.apply(op, e); err != nil {
+		return err
+	}
+	return f.maybeSnapshot()
+}
+
+// append writes one entry to the journal and syncs it. Callers hold f.mu.
+func (f *FileRepository) append(op string, e Exhibition) error {
+	line, err := json.Marshal(journalEntry{Seq: f.seq + 1, Op: op, Exhibition: e})
+	if err != nil {
+		return err
+	}
+	if _, err := f.journal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := f.journal.Sync(); err != nil {
+		return err
+	}
+	f.seq++
+	f.pending++
+	return nil
+}
+
+func (f *FileRepository) maybeSnapshot() error {
+	if f.SnapshotEvery <= 0 || f.pending < f.SnapshotEvery {
+		return nil
+	}
+	return f.writeSnapshot()
+}
+
+// Snapshot writes the full store to disk and truncates the journal.
+func (f *FileRepository) Snapshot() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeSnapshot()
+}
+
+func (f *FileRepository) writeSnapshot() error {
+	content, err := json.Marshal(snapshot{Seq: f.seq, Exhibitions: f.mem.GetAll()})
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it over the old snapshot so a
+	// crash never leaves a half-written snapshot behind.
+	tmp := f.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	if err := f.journal.Truncate(0); err != nil {
+		return err
+	}
+	f.pending = 0
+	return nil
+}
+
+// Close snapshots the store and closes the journal.
+func (f *FileRepository) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.writeSnapshot(); err != nil {
+		f.journal.Close()
+		return err
+	}
+	return f.journal.Close()
+}
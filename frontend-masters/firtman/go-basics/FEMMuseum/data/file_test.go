@@ -0,0 +1,176 @@
+package data
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestFileRepositoryReplaysJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "museum.json")
+	repo, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.SnapshotEvery = 3
+	for _, title := range []string{"one", "two", "three", "four"} {
+		if _, err := repo.Add(Exhibition{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Simulate a crash: the journal is left behind without a final snapshot.
+	repo.journal.Close()
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	all := reopened.GetAll()
+	if len(all) != 4 || all[0].Title != "one" || all[3].Title != "four" {
+		t.Errorf("got %+v after reopening, want the four exhibitions in order", all)
+	}
+}
+
+func TestFileRepositoryConcurrentAdds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "museum.json")
+	repo, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.SnapshotEvery = 7
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.Add(Exhibition{Title: "concurrent"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := len(reopened.GetAll()); got != 50 {
+		t.Errorf("got %d exhibitions, want 50", got)
+	}
+}
+
+func TestSeedOnlyFillsEmptyStore(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Add(Exhibition{Title: "existing"})
+	if err := Seed(repo); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(repo.GetAll()); got != 1 {
+		t.Errorf("got %d exhibitions, want the store left alone", got)
+	}
+}
+
+func TestFileRepositoryReplaysUpdatesAndDeletes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "museum.json")
+	repo, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := repo.Add(Exhibition{Title: "Sea Monsters"})
+	second, _ := repo.Add(Exhibition{Title: "Sea Monsters"})
+	if first.Slug != "sea-monsters" || second.Slug != "sea-monsters-2" {
+		t.Errorf("got slugs %q and %q, want them made unique", first.Slug, second.Slug)
+	}
+	first.Description = "updated"
+	if _, err := repo.Update(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	repo.journal.Close()
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, err := reopened.Get(first.ID)
+	if err != nil || got.Description != "updated" || got.Slug != "sea-monsters" {
+		t.Errorf("got %+v, %v; want the updated exhibition", got, err)
+	}
+	if _, err := reopened.Get(second.ID); err != ErrNotFound {
+		t.Errorf("got %v for a deleted exhibition, want ErrNotFound", err)
+	}
+}
+
+// crash closes repo without a snapshot and leaves torn at the end of its
+// journal, like a write interrupted by a crash.
+func crash(t *testing.T, repo *FileRepository, torn string) {
+	t.Helper()
+	repo.journal.Close()
+	file, err := os.OpenFile(repo.journalPath(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(torn)
+	file.Close()
+}
+
+func TestFileRepositoryWritesAfterATornLineSurvive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "museum.json")
+	repo, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Add(Exhibition{Title: "one"})
+	crash(t, repo, `{"seq":2,"op":"ad`)
+
+	repo, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Add(Exhibition{Title: "two"})
+	repo.Add(Exhibition{Title: "three"})
+	repo.journal.Close()
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	var titles []string
+	for _, e := range reopened.GetAll() {
+		titles = append(titles, e.Title)
+	}
+	if !slices.Equal(titles, []string{"one", "two", "three"}) {
+		t.Errorf("got %q after reopening", titles)
+	}
+}
+
+func TestFileRepositoryRefusesACorruptJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "museum.json")
+	repo, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Add(Exhibition{Title: "one"})
+	crash(t, repo, "garbage\n")
+	line, _ := json.Marshal(journalEntry{Seq: 2, Op: opAdd, Exhibition: Exhibition{ID: "2", Title: "two"}})
+	file, _ := os.OpenFile(repo.journalPath(), os.O_WRONLY|os.O_APPEND, 0)
+	file.Write(append(line, '\n'))
+	file.Close()
+
+	if _, err := OpenFile(path); err == nil {
+		t.Error("a bad line in the middle of the journal was skipped")
+	}
+}
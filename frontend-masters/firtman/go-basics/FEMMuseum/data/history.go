@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Existing is the type of the first revision of an exhibition written
+// before history was kept: it holds the exhibition as it was first seen.
+const Existing ChangeType = "existing"
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision is an exhibition as a write left it, or as it was right before
+// being deleted. Revisions are never changed once recorded.
+type Revision struct {
+	ExhibitionID string     `json:"exhibitionId"`
+	Number       int        `json:"number"` // counts from 1 for every exhibition
+	Type         ChangeType `json:"type"`
+	Author       string     `json:"author,omitempty"`
+	Time         time.Time  `json:"time,omitzero"`
+	Restores     int        `json:"restores,omitempty"` // the revision a rollback brought back
+	Exhibition   Exhibition `json:"exhibition"`
+}
+
+// History keeps the revisions of every exhibition, in memory and, when
+// opened from a file, appended to it as JSON lines.
+type History struct {
+	mu   sync.RWMutex
+	file *os.File
+	byID map[string][]Revision
+}
+
+func NewHistory() *History {
+	return &History{byID: make(map[string][]Revision)}
+}
+
+// OpenHistory loads the revisions in path and appends new ones to it.
+func OpenHistory(path string) (*History, error) {
+	h := NewHistory()
+	if err := h.load(path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	h.file = file
+	return h, nil
+}
+
+func (h *History) load(path string) error {
+	return ReadJournal(path, func(rev Revision, _ int) error {
+		h.byID[rev.ExhibitionID] = append(h.byID[rev.ExhibitionID], rev)
+		return nil
+	})
+}
+
+func (h *History) Close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
+
+// record numbers rev and stores it.
+func (h *History) record(rev Revision) (Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rev.Number = len(h.byID[rev.ExhibitionID]) + 1
+	if h.file != nil {
+		line, err := json.Marshal(rev)
+		if err != nil {
+			return Revision{}, err
+		}
+		if _, err := h.file.Write(append(line, '\n')); err != nil {
+			return Revision{}, err
+		}
+		if err := h.file.Sync(); err != nil {
+			return Revision{}, err
+		}
+	}
+	h.byID[rev.ExhibitionID] = append(h.byID[rev.ExhibitionID], rev)
+	return rev, nil
+}
+
+func (h *History) revisions(id string) []Revision {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]Revision(nil), h.byID[id]...)
+}
+
+var history = NewHistory()
+
+// UseHistory sets where revisions are kept. Call it at startup, before the
+// server starts handling requests.
+func UseHistory(h *History) {
+	history = h
+}
+
+// recordChange stores the revision for c. previous is the exhibition before
+// the write, so exhibitions from before history was kept still get their
+// original state recorded. Callers hold writeMu.
+func recordChange(c Change, previous *Exhibition, restores int) {
+	id := c.Exhibition.ID
+	if previous != nil && len(history.revisions(id)) == 0 {
+		if _, err := history.record(Revision{ExhibitionID: id, Type: Existing, Time: c.Time, Exhibition: *previous}); err != nil {
+			logHistoryError(id, err)
+		}
+	}
+	rev := Revision{ExhibitionID: id, Type: c.Type, Author: c.Author, Time: c.Time, Restores: restores, Exhibition: c.Exhibition}
+	if _, err := history.record(rev); err != nil {
+		logHistoryError(id, err)
+	}
+}
+
+// The write itself already succeeded, so a revision that can't be saved
+// is reported rather than failing it.
+func logHistoryError(id string, err error) {
+	log.Printf("Couldn't record a revision of exhibition %s: %v", id, err)
+}
+
+// Revisions returns the revisions of the exhibition with id, oldest first.
+func Revisions(id string) ([]Revision, error) {
+	list := history.revisions(id)
+	if len(list) > 0 {
+		return list, nil
+	}
+	// Untouched since history was kept: its current state is all there is,
+	// and when it was written is unknown.
+	e, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return []Revision{{ExhibitionID: id, Number: 1, Type: Existing, Exhibition: e}}, nil
+}
+
+// GetRevision returns revision number n of the exhibition with id.
+func GetRevision(id string, n int) (Revision, error) {
+	list, err := Revisions(id)
+	if err != nil {
+		return Revision{}, err
+	}
+	if n < 1 || n > len(list) {
+		return Revision{}, ErrRevisionNotFound
+	}
+	return list[n-1], nil
+}
+
+// Restore brings the exhibition with id back to revision n. It's a write
+// like any other and adds a new revision rather than removing any.
+// Deleted exhibitions can't be restored, since their ID is gone.
+func Restore(ctx context.Context, id string, n int) (Exhibition, error) {
+	rev, err := GetRevision(id, n)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	e := rev.Exhibition
+	e.ID = id
+	return update(ctx, e, n)
+}
+
+// FieldChange is a field that differs between two versions of an exhibition.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// Diff lists the fields that differ from a to b.
+func Diff(a, b Exhibition) []FieldChange {
+	changes := []FieldChange{}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := range va.NumField() {
+		from, to := va.Field(i).Interface(), vb.Field(i).Interface()
+		if !reflect.DeepEqual(from, to) {
+			changes = append(changes, FieldChange{Field: va.Type().Field(i).Name, From: from, To: to})
+		}
+	}
+	return changes
+}
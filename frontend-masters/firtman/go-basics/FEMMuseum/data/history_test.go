@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreAddsARevision(t *testing.T) {
+	defer Use(repo)
+	defer UseHistory(history)
+	Use(NewMemoryRepository())
+	path := filepath.Join(t.TempDir(), "store.json.history")
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseHistory(h)
+
+	ctx := WithAuthor(context.Background(), "ana")
+	e, _ := Add(ctx, Exhibition{Title: "Tides", Description: "first"})
+	e.Description = "second"
+	if _, err := Update(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := Restore(WithAuthor(context.Background(), "ben"), e.ID, 1)
+	if err != nil || restored.Description != "first" {
+		t.Fatalf("got %+v, %v", restored, err)
+	}
+	h.Close()
+
+	// Revisions survive a restart.
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	UseHistory(h)
+	list, _ := Revisions(e.ID)
+	if len(list) != 3 {
+		t.Fatalf("got %d revisions, want 3", len(list))
+	}
+	last := list[2]
+	if last.Number != 3 || last.Author != "ben" || last.Restores != 1 || last.Exhibition.Description != "first" {
+		t.Errorf("got last revision %+v", last)
+	}
+	changes := Diff(list[0].Exhibition, list[1].Exhibition)
+	if len(changes) != 1 || changes[0].Field != "Description" || changes[0].To != "second" {
+		t.Errorf("got diff %+v", changes)
+	}
+}
+
+func TestRevisionsAfterATornLineSurvive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json.history")
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.record(Revision{ExhibitionID: "1", Type: Created})
+	h.file.WriteString(`{"exhibitionId":"1","numb`) // a crash mid-write
+	h.Close()
+
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.record(Revision{ExhibitionID: "1", Type: Updated})
+	h.record(Revision{ExhibitionID: "1", Type: Deleted})
+	h.Close()
+
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	list := h.revisions("1")
+	if len(list) != 3 || list[2].Number != 3 || list[2].Type != Deleted {
+		t.Errorf("got %+v", list)
+	}
+}
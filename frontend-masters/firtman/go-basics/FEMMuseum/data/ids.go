@@ -0,0 +1,53 @@
+package data
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Slugify turns a title into a URL friendly slug:
+// "Aristotle: Life and Legacy" becomes "aristotle-life-and-legacy".
+func Slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "exhibition"
+	}
+	return b.String()
+}
+
+// uniqueSlug returns base, or base with a numeric suffix, so that no other
+// exhibition than the one with id uses it.
+func uniqueSlug(list []Exhibition, base, id string) string {
+	taken := make(map[string]bool, len(list))
+	for _, e := range list {
+		if e.ID != id {
+			taken[e.Slug] = true
+		}
+	}
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}
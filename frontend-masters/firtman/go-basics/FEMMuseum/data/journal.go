@@ -0,0 +1,65 @@
+package data
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ReadJournal decodes every line of the JSON lines journal at path and
+// passes it to fn with its line number. A missing journal is empty.
+//
+// A crash in the middle of an append leaves a torn last line: one without
+// its newline or that doesn't decode. That write was never acknowledged, so
+// the line is cut off the file, letting new entries start on a line of
+// their own. A bad line followed by others is corruption and an error.
+func ReadJournal[T any](path string, fn func(entry T, line int) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var good int64 // offset after the last good line
+	var torn error
+	for line := 1; ; line++ {
+		content, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if len(content) == 0 {
+			break
+		}
+		if torn != nil {
+			return torn
+		}
+		var entry T
+		if content[len(content)-1] != '\n' {
+			// Without its newline a line was cut short, even when what's
+			// there decodes.
+			torn = fmt.Errorf("journal %s line %d is incomplete", path, line)
+		} else if err := json.Unmarshal(content, &entry); err != nil {
+			torn = fmt.Errorf("journal %s line %d is corrupt: %w", path, line, err)
+		} else {
+			if err := fn(entry, line); err != nil {
+				return err
+			}
+			good += int64(len(content))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	if torn != nil {
+		return os.Truncate(path, good)
+	}
+	return nil
+}
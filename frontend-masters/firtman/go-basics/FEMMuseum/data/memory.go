@@ -0,0 +1,148 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+)
+
+type MemoryRepository struct {
+	mu   sync.RWMutex
+	list []Exhibition
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (m *MemoryRepository) Add(e Exhibition) (Exhibition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e = m.prepareAdd(e)
+	m.list = append(m.list, e)
+	return e, nil
+}
+
+func (m *MemoryRepository) Get(id string) (Exhibition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := m.index(id)
+	if i < 0 {
+		return Exhibition{}, ErrNotFound
+	}
+	return m.list[i], nil
+}
+
+// GetAll returns a copy, so callers can't race with later writes.
+func (m *MemoryRepository) GetAll() []Exhibition {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]Exhibition, len(m.list))
+	copy(list, m.list)
+	return list
+}
+
+func (m *MemoryRepository) Update(e Exhibition) (Exhibition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.prepareUpdate(e)
+	if err != nil {
+		return Exhibition{}, err
+	}
+	m.list[m.index(e.ID)] = e
+	return e, nil
+}
+
+func (m *MemoryRepository) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.list = append(m.list[:i:i], m.list[i+1:]...)
+	return nil
+}
+
+// The helpers below expect the caller to hold m.mu, or, like FileRepository,
+// to otherwise be the only writer.
+
+func (m *MemoryRepository) index(id string) int {
+	for i, e := range m.list {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemoryRepository) prepareAdd(e Exhibition) Exhibition {
+	e.ID = newID()
+	base := e.Slug
+	if base == "" {
+		base = Slugify(e.Title)
+	}
+	e.Slug = uniqueSlug(m.list, base, e.ID)
+	return e
+}
+
+func (m *MemoryRepository) prepareUpdate(e Exhibition) (Exhibition, error) {
+	i := m.index(e.ID)
+	if i < 0 {
+		return Exhibition{}, ErrNotFound
+	}
+	if e.Slug == "" {
+		e.Slug = m.list[i].Slug
+	} else if uniqueSlug(m.list, e.Slug, e.ID) != e.Slug {
+		return Exhibition{}, ErrSlugTaken
+	}
+	return e, nil
+}
+
+// apply performs an already prepared journal operation.
+func (m *MemoryRepository) apply(op string, e Exhibition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch op {
+	case opAdd:
+		m.list = append(m.list, e)
+	case opPut:
+		i := m.index(e.ID)
+		if i < 0 {
+			return ErrNotFound
+		}
+		m.list[i] = e
+	case opDelete:
+		i := m.index(e.ID)
+		if i < 0 {
+			return ErrNotFound
+		}
+		m.list = append(m.list[:i:i], m.list[i+1:]...)
+	default:
+		return fmt.Errorf("unknown op %q", op)
+	}
+	return nil
+}
+
+func (m *MemoryRepository) assignMissingIDs() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assigned := false
+	for i, e := range m.list {
+		if e.ID != "" {
+			continue
+		}
+		e.ID = newID()
+		if e.Slug == "" {
+			e.Slug = uniqueSlug(m.list, Slugify(e.Title), e.ID)
+		}
+		m.list[i] = e
+		assigned = true
+	}
+	return assigned
+}
+
+func (m *MemoryRepository) replace(list []Exhibition) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.list = list
+}
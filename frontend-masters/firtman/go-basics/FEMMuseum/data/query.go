@@ -0,0 +1,110 @@
+package data
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Sort orders understood by Query.
+const (
+	SortTitle     = "title"
+	SortTitleDesc = "-title"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Query filters, sorts and paginates a list of exhibitions. It only works on
+// the Exhibition values themselves, so it can be applied to the result of
+// any Repository.
+type Query struct {
+	Open   *bool  // only opened or closed exhibitions when set
+	Color  string // only exhibitions with this color when set
+	Sort   string // insertion order when empty
+	Limit  int    // everything when zero
+	Offset int
+}
+
+// Page is one page of a Query result. Next and Prev are cursors for the
+// neighbouring pages, empty when there is no such page.
+type Page struct {
+	Items []Exhibition
+	Total int // number of matches across all pages
+	Next  string
+	Prev  string
+}
+
+func (q Query) matches(e Exhibition) bool {
+	if q.Open != nil && e.CurrentlyOpened != *q.Open {
+		return false
+	}
+	if q.Color != "" && e.Color != q.Color {
+		return false
+	}
+	return true
+}
+
+// Apply runs q against list, which is left untouched.
+func (q Query) Apply(list []Exhibition) Page {
+	var matches []Exhibition
+	for _, e := range list {
+		if q.matches(e) {
+			matches = append(matches, e)
+		}
+	}
+
+	switch q.Sort {
+	case SortTitle, SortTitleDesc:
+		slices.SortStableFunc(matches, func(a, b Exhibition) int {
+			c := cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+			if q.Sort == SortTitleDesc {
+				return -c
+			}
+			return c
+		})
+	}
+
+	page := Page{Total: len(matches)}
+	start := min(max(q.Offset, 0), len(matches))
+	end := len(matches)
+	if q.Limit > 0 {
+		end = min(start+q.Limit, len(matches))
+		if end < len(matches) {
+			page.Next = EncodeCursor(end)
+		}
+		if start > 0 {
+			page.Prev = EncodeCursor(max(start-q.Limit, 0))
+		}
+	}
+	page.Items = matches[start:end]
+	if page.Items == nil {
+		page.Items = []Exhibition{}
+	}
+	return page
+}
+
+// Find runs q against the current store.
+func Find(q Query) Page {
+	return q.Apply(GetAll())
+}
+
+// Cursors are opaque to clients so the pagination scheme can change later.
+
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "o:"))
+	if err != nil || offset < 0 || !strings.HasPrefix(string(raw), "o:") {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
@@ -0,0 +1,27 @@
+package data
+
+import "testing"
+
+func TestQueryPaginatesSortedMatches(t *testing.T) {
+	opened := true
+	list := []Exhibition{
+		{Title: "delta", CurrentlyOpened: true},
+		{Title: "Alpha", CurrentlyOpened: true},
+		{Title: "charlie", CurrentlyOpened: false},
+		{Title: "bravo", CurrentlyOpened: true},
+	}
+	q := Query{Open: &opened, Sort: SortTitle, Limit: 2}
+
+	first := q.Apply(list)
+	if first.Total != 3 || len(first.Items) != 2 || first.Items[0].Title != "Alpha" || first.Prev != "" {
+		t.Fatalf("got first page %+v", first)
+	}
+	q.Offset, _ = DecodeCursor(first.Next)
+	second := q.Apply(list)
+	if len(second.Items) != 1 || second.Items[0].Title != "delta" || second.Next != "" {
+		t.Fatalf("got second page %+v", second)
+	}
+	if offset, _ := DecodeCursor(second.Prev); offset != 0 {
+		t.Errorf("got prev offset %d, want 0", offset)
+	}
+}
@@ -0,0 +1,11 @@
+package data
+
+// Repository is a store of exhibitions. Implementations must be safe for
+// concurrent use, return ErrNotFound for unknown IDs and keep slugs unique.
+type Repository interface {
+	Add(e Exhibition) (Exhibition, error)
+	Get(id string) (Exhibition, error)
+	GetAll() []Exhibition
+	Update(e Exhibition) (Exhibition, error)
+	Delete(id string) error
+}
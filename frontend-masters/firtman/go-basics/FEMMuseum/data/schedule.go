@@ -0,0 +1,100 @@
+package data
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Formats used by schedules. Dates and times are wall clock values in the
+// museum's time zone.
+const (
+	DateFormat = "2006-01-02"
+	TimeFormat = "15:04"
+)
+
+// Schedule says when an exhibition can be visited. When an exhibition has
+// one, its CurrentlyOpened is computed from the clock instead of stored.
+type Schedule struct {
+	Opens    string         `json:",omitempty"` // first day, open since forever when empty
+	Closes   string         `json:",omitempty"` // last day, open-ended when empty
+	Hours    []OpeningHours `json:",omitempty"` // open all day on every day when empty
+	Closures []string       `json:",omitempty"` // holidays and other closed days
+}
+
+// OpeningHours are the hours of one day of the week. A day can be listed
+// more than once, like for a lunch break.
+type OpeningHours struct {
+	Day   string // lowercase English name, like "monday"
+	Open  string
+	Close string
+}
+
+// TimedEntry makes an exhibition bookable: the opening hours of its
+// Schedule are cut into slots of SlotMinutes, each admitting up to Capacity
+// visitors.
+type TimedEntry struct {
+	SlotMinutes int
+	Capacity    int
+}
+
+var Weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+var (
+	location = time.UTC
+	// now is the clock CurrentlyOpened is computed from; tests replace it.
+	now = time.Now
+)
+
+// SetLocation sets the museum's time zone. Call it at startup, before the
+// server starts handling requests.
+func SetLocation(loc *time.Location) {
+	location = loc
+}
+
+func Location() *time.Location {
+	return location
+}
+
+// OpenAt reports whether the schedule has the exhibition open at t.
+// Malformed values never count as open; validation keeps them out.
+func (s Schedule) OpenAt(t time.Time) bool {
+	t = t.In(location)
+	day := t.Format(DateFormat)
+	if s.Opens != "" && day < s.Opens {
+		return false
+	}
+	if s.Closes != "" && day > s.Closes {
+		return false
+	}
+	if slices.Contains(s.Closures, day) {
+		return false
+	}
+	if len(s.Hours) == 0 {
+		return true
+	}
+	weekday := Weekdays[t.Weekday()]
+	clock := t.Format(TimeFormat)
+	for _, h := range s.Hours {
+		if strings.EqualFold(h.Day, weekday) && h.Open <= clock && clock < h.Close {
+			return true
+		}
+	}
+	return false
+}
+
+// withStatus fills in CurrentlyOpened for scheduled exhibitions.
+func withStatus(e Exhibition, t time.Time) Exhibition {
+	if e.Schedule != nil {
+		e.CurrentlyOpened = e.Schedule.OpenAt(t)
+	}
+	return e
+}
+
+func withStatuses(list []Exhibition) []Exhibition {
+	t := now()
+	for i := range list {
+		list[i] = withStatus(list[i], t)
+	}
+	return list
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScheduleOpenAtUsesMuseumTime(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	SetLocation(paris)
+	defer SetLocation(time.UTC)
+
+	s := Schedule{
+		Opens:    "2024-03-01",
+		Closes:   "2024-06-30",
+		Hours:    []OpeningHours{{Day: "saturday", Open: "10:00", Close: "12:00"}, {Day: "saturday", Open: "14:00", Close: "18:00"}},
+		Closures: []string{"2024-05-04"},
+	}
+	for _, c := range []struct {
+		when string // UTC
+		want bool
+	}{
+		{"2024-04-06T08:30:00Z", true},  // 10:30 in Paris (summer time)
+		{"2024-04-06T10:30:00Z", false}, // lunch break
+		{"2024-04-06T15:59:00Z", true},
+		{"2024-04-06T16:00:00Z", false}, // closing time is exclusive
+		{"2024-04-07T09:00:00Z", false}, // sunday
+		{"2024-05-04T09:00:00Z", false}, // closure
+		{"2024-02-24T09:00:00Z", false}, // before the opening day
+		{"2024-06-29T09:00:00Z", true},
+		{"2024-07-06T09:00:00Z", false}, // after the closing day
+	} {
+		at, _ := time.Parse(time.RFC3339, c.when)
+		if got := s.OpenAt(at); got != c.want {
+			t.Errorf("OpenAt(%s) = %v, want %v", c.when, got, c.want)
+		}
+	}
+}
+
+func TestScheduledExhibitionsComputeCurrentlyOpened(t *testing.T) {
+	defer Use(repo)
+	defer func() { now = time.Now }()
+	Use(NewMemoryRepository())
+
+	now = func() time.Time { return time.Date(2024, 4, 6, 11, 0, 0, 0, time.UTC) }
+	added, err := Add(context.Background(), Exhibition{Title: "Tides", CurrentlyOpened: true, Schedule: &Schedule{Opens: "2024-05-01"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.CurrentlyOpened {
+		t.Error("an exhibition that isn't open yet is reported open")
+	}
+	before := Version()
+
+	now = func() time.Time { return time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC) }
+	if got, _ := Get(added.ID); !got.CurrentlyOpened {
+		t.Error("the exhibition didn't open on its opening day")
+	}
+	if Version() == before {
+		t.Error("the version didn't change when the exhibition opened")
+	}
+}
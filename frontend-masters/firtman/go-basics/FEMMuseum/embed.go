@@ -0,0 +1,28 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+// site holds the static site and the templates, so the binary runs from any
+// directory. The gallery is listed file by file to leave out uploads.
+//
+//go:embed public/*.html public/*.css public/*.js public/images
+//go:embed public/gallery/*.*
+//go:embed templates
+var site embed.FS
+
+// siteDir returns dir on disk when it's set, or the embedded directory
+// embedded otherwise.
+func siteDir(dir, embedded string) fs.FS {
+	if dir != "" {
+		return os.DirFS(dir)
+	}
+	sub, err := fs.Sub(site, embedded)
+	if err != nil {
+		panic(err) // embedded is one of the directories above
+	}
+	return sub
+}
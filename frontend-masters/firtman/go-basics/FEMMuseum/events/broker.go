@@ -0,0 +1,204 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+const (
+	DefaultLogSize    = 256
+	heartbeatInterval = 15 * time.Second
+	writeTimeout      = 10 * time.Second
+	clientBuffer      = 32
+	retryMillis       = 3000
+)
+
+// Event is one message of the stream. IDs look like "<epoch>-<seq>": the
+// epoch changes on restart, so a client resuming from an older process is
+// told to reload instead of silently missing events.
+type Event struct {
+	Seq  uint64
+	Type string
+	Data []byte
+}
+
+type payload struct {
+	Type       data.ChangeType `json:"type"`
+	Exhibition data.Exhibition `json:"exhibition"`
+}
+
+// Broker fans store changes out to Server-Sent Events clients and keeps
+// the last events so reconnecting clients can resume with Last-Event-ID.
+type Broker struct {
+	epoch string
+
+	mu      sync.Mutex
+	seq     uint64
+	log     []Event // ring buffer of the last events, oldest first
+	size    int
+	clients map[chan Event]struct{}
+	closed  chan struct{}
+}
+
+func NewBroker(logSize int) *Broker {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return &Broker{
+		epoch:   hex.EncodeToString(b),
+		size:    logSize,
+		clients: make(map[chan Event]struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Publish sends a store change to every client; pass it to data.Subscribe.
+// It never blocks: a client too slow to keep up is disconnected and can
+// resume from the log when it reconnects.
+func (b *Broker) Publish(c data.Change) {
+	body, err := json.Marshal(payload{Type: c.Type, Exhibition: c.Exhibition})
+	if err != nil {
+		log.Printf("Couldn't encode %s event: %v", c.Type, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	event := Event{Seq: b.seq, Type: string(c.Type), Data: body}
+	b.log = append(b.log, event)
+	if len(b.log) > b.size {
+		b.log = b.log[len(b.log)-b.size:]
+	}
+	for client := range b.clients {
+		select {
+		case client <- event:
+		default:
+			delete(b.clients, client)
+			close(client)
+		}
+	}
+}
+
+// subscribe registers a client and returns the logged events after
+// lastID. When those events were already dropped from the log or lastID
+// comes from another process, it returns reset instead, holding the current
+// position, and the client must reload everything.
+func (b *Broker) subscribe(lastID string) (client chan Event, missed []Event, reset *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	client = make(chan Event, clientBuffer)
+	b.clients[client] = struct{}{}
+
+	if lastID == "" {
+		return client, nil, nil
+	}
+	epoch, seqText, _ := strings.Cut(lastID, "-")
+	last, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil || epoch != b.epoch || last > b.seq || (len(b.log) > 0 && b.log[0].Seq > last+1) {
+		return client, nil, &Event{Seq: b.seq, Type: "reset", Data: []byte("{}")}
+	}
+	for _, event := range b.log {
+		if event.Seq > last {
+			missed = append(missed, event)
+		}
+	}
+	return client, missed, nil
+}
+
+func (b *Broker) unsubscribe(client chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[client]; ok {
+		delete(b.clients, client)
+		close(client)
+	}
+}
+
+// Close ends every open stream, which http.Server.Shutdown can't do by
+// itself since streams never go idle.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+}
+
+func (b *Broker) id(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// ServeHTTP streams events as text/event-stream.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	client, missed, reset := b.subscribe(lastID)
+	defer b.unsubscribe(client)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Accel-Buffering", "no") // keep proxies from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	send := func(format string, args ...any) bool {
+		rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	sendEvent := func(e Event) bool {
+		return send("id: %s\nevent: %s\ndata: %s\n\n", b.id(e.Seq), e.Type, e.Data)
+	}
+
+	if !send("retry: %d\n\n", retryMillis) {
+		return
+	}
+	if reset != nil && !sendEvent(*reset) {
+		return
+	}
+	for _, e := range missed {
+		if !sendEvent(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-client:
+			if !ok {
+				// Dropped for being too slow; it'll reconnect and resume.
+				return
+			}
+			if !sendEvent(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if !send(": heartbeat\n\n") {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-b.closed:
+			return
+		}
+	}
+}
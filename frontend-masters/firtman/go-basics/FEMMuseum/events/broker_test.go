@@ -0,0 +1,75 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// readEvents reads the id and event lines of a stream until n events came.
+func readEvents(t *testing.T, url, lastID string, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var got []string
+	scanner := bufio.NewScanner(res.Body)
+	for len(got) < n && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "event: ") {
+			got = append(got, strings.TrimPrefix(line, "event: "))
+		}
+	}
+	return got
+}
+
+func TestResumeFromLastEventID(t *testing.T) {
+	b := NewBroker(2)
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	b.Publish(data.Change{Type: data.Created})
+	b.Publish(data.Change{Type: data.Updated})
+	b.Publish(data.Change{Type: data.Deleted})
+
+	// Event 1 is still logged as the one before 2, so 2 and 3 are replayed.
+	if got := readEvents(t, server.URL, b.id(1), 2); strings.Join(got, ",") != "updated,deleted" {
+		t.Errorf("got %v, want the events after 1", got)
+	}
+	// Event 1 is gone from the log, so there is a gap.
+	if got := readEvents(t, server.URL, b.id(0), 1); len(got) != 1 || got[0] != "reset" {
+		t.Errorf("got %v, want a reset", got)
+	}
+	if got := readEvents(t, server.URL, "otherprocess-3", 1); len(got) != 1 || got[0] != "reset" {
+		t.Errorf("got %v, want a reset for another epoch", got)
+	}
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	b := NewBroker(DefaultLogSize)
+	client, _, _ := b.subscribe("")
+	for range clientBuffer + 1 {
+		b.Publish(data.Change{Type: data.Created})
+	}
+	for range client {
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.clients) != 0 {
+		t.Error("got the slow client still subscribed")
+	}
+}
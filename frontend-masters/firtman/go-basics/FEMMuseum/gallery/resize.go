@@ -0,0 +1,91 @@
+package gallery
+
+import (
+	"image"
+	"image/draw"
+)
+
+// contribution is how much a source row or column adds to a target one.
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// weights maps every target coordinate to the source coordinates it covers
+// when shrinking src pixels to dst, weighted by the covered fraction.
+func weights(src, dst int) [][]contribution {
+	scale := float64(src) / float64(dst)
+	all := make([][]contribution, dst)
+	for d := range dst {
+		start, end := float64(d)*scale, float64(d+1)*scale
+		for s := int(start); s < src && float64(s) < end; s++ {
+			covered := min(end, float64(s+1)) - max(start, float64(s))
+			if covered > 0 {
+				all[d] = append(all[d], contribution{index: s, weight: covered / scale})
+			}
+		}
+	}
+	return all
+}
+
+// fit returns the size of w x h scaled down to fit in maxW x maxH, keeping
+// the aspect ratio. Images that already fit keep their size.
+func fit(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+	return max(1, w*maxH/h), maxH
+}
+
+// Resize shrinks src to fit in maxW x maxH by averaging the source pixels
+// each target pixel covers, which avoids the aliasing of nearest neighbour
+// scaling without needing anything outside the standard library.
+func Resize(src image.Image, maxW, maxH int) *image.RGBA {
+	bounds := src.Bounds()
+	// Work on premultiplied RGBA so transparent pixels don't bleed color.
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	w, h := fit(bounds.Dx(), bounds.Dy(), maxW, maxH)
+	if w == bounds.Dx() && h == bounds.Dy() {
+		return rgba
+	}
+	xs, ys := weights(bounds.Dx(), w), weights(bounds.Dy(), h)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, yc := range ys {
+		for x, xc := range xs {
+			var r, g, b, a float64
+			for _, cy := range yc {
+				row := rgba.Pix[cy.index*rgba.Stride:]
+				for _, cx := range xc {
+					weight := cy.weight * cx.weight
+					p := row[cx.index*4 : cx.index*4+4]
+					r += float64(p[0]) * weight
+					g += float64(p[1]) * weight
+					b += float64(p[2]) * weight
+					a += float64(p[3]) * weight
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = clamp(r)
+			dst.Pix[i+1] = clamp(g)
+			dst.Pix[i+2] = clamp(b)
+			dst.Pix[i+3] = clamp(a)
+		}
+	}
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	v += 0.5
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
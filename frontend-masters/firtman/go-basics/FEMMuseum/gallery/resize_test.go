@@ -0,0 +1,23 @@
+package gallery
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeKeepsAspectRatioAndColor(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 900, 300))
+	for y := range 300 {
+		for x := range 900 {
+			src.Set(x, y, color.NRGBA{R: 200, G: 40, B: 10, A: 255})
+		}
+	}
+	dst := Resize(src, 320, 320)
+	if got := dst.Bounds().Size(); got != image.Pt(320, 106) {
+		t.Fatalf("got size %v, want 320x106", got)
+	}
+	if got := dst.RGBAAt(160, 50); got != (color.RGBA{R: 200, G: 40, B: 10, A: 255}) {
+		t.Errorf("got color %v, want the source color averaged unchanged", got)
+	}
+}
@@ -0,0 +1,201 @@
+package gallery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+const (
+	// UploadDir is where uploads live, relative to the gallery.
+	UploadDir = "uploads"
+
+	MaxUploadBytes = 10 << 20
+	maxPixels      = 40_000_000 // refuse decompression bombs before decoding
+
+	thumbnailSize = 320
+	mediumSize    = 1024
+)
+
+var (
+	ErrNotAnImage    = errors.New("the file is not a PNG, JPEG or GIF image")
+	ErrImageTooLarge = errors.New("the image has too many pixels")
+)
+
+// formats maps the sniffed content types we accept to the file extension
+// their uploads are stored with.
+var formats = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+// Store saves uploaded images into a directory, which is served as the
+// gallery's UploadDir under URLPrefix.
+type Store struct {
+	dir       string
+	urlPrefix string
+}
+
+func NewStore(dir, urlPrefix string) *Store {
+	return &Store{dir: dir, urlPrefix: strings.TrimSuffix(urlPrefix, "/")}
+}
+
+// Save checks that content is an image and stores it under a name derived
+// from its hash, along with a thumbnail and a medium sized copy. Uploading
+// the same image twice stores it once. It returns the image name to use as
+// Exhibition.Image.
+func (s *Store) Save(content []byte) (string, error) {
+	ext, ok := formats[http.DetectContentType(content)]
+	if !ok {
+		return "", ErrNotAnImage
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return "", ErrNotAnImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return "", ErrImageTooLarge
+	}
+
+	sum := sha256.Sum256(content)
+	name := path.Join(UploadDir, hex.EncodeToString(sum[:16])+ext)
+	if s.complete(name) {
+		return name, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return "", ErrNotAnImage
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+	for _, v := range []struct {
+		suffix string
+		size   int
+	}{{"thumb", thumbnailSize}, {"medium", mediumSize}} {
+		var buf bytes.Buffer
+		if err := encode(&buf, variantExt(ext), Resize(img, v.size, v.size)); err != nil {
+			return "", err
+		}
+		if err := writeFile(s.file(variantName(name, v.suffix)), buf.Bytes()); err != nil {
+			return "", err
+		}
+	}
+	// The original goes last: its presence is what marks an upload complete.
+	if err := writeFile(s.file(name), content); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// complete reports whether the upload name and its copies are all stored. A
+// copy deleted since is generated again by saving the image once more.
+func (s *Store) complete(name string) bool {
+	for _, file := range []string{name, variantName(name, "thumb"), variantName(name, "medium")} {
+		if _, err := os.Stat(s.file(file)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Variants returns the URLs of the generated copies of image, or nil when
+// image wasn't uploaded through the Store.
+func (s *Store) Variants(image string) *data.ImageVariants {
+	if !strings.HasPrefix(image, UploadDir+"/") {
+		return nil
+	}
+	variants := &data.ImageVariants{Original: s.url(image)}
+	for suffix, field := range map[string]*string{"thumb": &variants.Thumbnail, "medium": &variants.Medium} {
+		name := variantName(image, suffix)
+		if _, err := os.Stat(s.file(name)); err != nil {
+			return nil
+		}
+		*field = s.url(name)
+	}
+	return variants
+}
+
+// file returns where the image named like "uploads/abc.png" is on disk.
+func (s *Store) file(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(strings.TrimPrefix(name, UploadDir+"/")))
+}
+
+func (s *Store) url(name string) string {
+	return s.urlPrefix + "/" + name
+}
+
+// IsOriginal reports whether the gallery file name is an image exhibitions
+// can show: a PNG, JPEG or GIF that isn't a copy generated for an upload.
+func IsOriginal(name string) bool {
+	ext := path.Ext(name)
+	switch strings.ToLower(ext) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+	default:
+		return false
+	}
+	base := strings.TrimSuffix(name, ext)
+	return !strings.HasSuffix(base, "-thumb") && !strings.HasSuffix(base, "-medium")
+}
+
+// variantName turns "uploads/abc.png" into "uploads/abc-thumb.png".
+func variantName(name, suffix string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + suffix + variantExt(ext)
+}
+
+// variantExt is the format copies are stored in. Resizing blends colors
+// outside a GIF's palette, so GIFs get PNG copies.
+func variantExt(ext string) string {
+	if ext == ".gif" {
+		return ".png"
+	}
+	return ext
+}
+
+func encode(w io.Writer, ext string, img image.Image) error {
+	if ext == ".jpg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(w, img)
+}
+
+// writeFile writes through a temporary file so a half written image is
+// never served.
+func writeFile(name string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("storing %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cache-Control values used across the server.
+const (
+	NoCache   = "no-cache" // may be stored, but must be revalidated first
+	Immutable = "public, max-age=31536000, immutable"
+)
+
+// NotModified sets the validators of a response and reports whether the
+// client's cached copy is still fresh, in which case it has already
+// answered 304 and the caller must not write a body. An empty etag or zero
+// modified skips that validator.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	fresh := false
+	// If-None-Match takes precedence over If-Modified-Since (RFC 9110 13.2.2).
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		fresh = etag != "" && matchesETag(match, etag)
+	} else if since := r.Header.Get("If-Modified-Since"); since != "" && !modified.IsZero() {
+		t, err := http.ParseTime(since)
+		fresh = err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	if !fresh {
+		return false
+	}
+	// A 304 carries no body, so drop what describes one.
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// matchesETag compares with the weak comparison If-None-Match calls for,
+// so a tag weakened by compression still matches.
+func matchesETag(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule sets Cache-Control to Value for paths starting with Prefix.
+type Rule struct {
+	Prefix string
+	Value  string
+}
+
+// Control sets Cache-Control from the first rule matching the request path.
+// Handlers can still override it.
+func Control(rules []Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rules {
+				if strings.HasPrefix(r.URL.Path, rule.Prefix) {
+					w.Header().Set("Cache-Control", rule.Value)
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
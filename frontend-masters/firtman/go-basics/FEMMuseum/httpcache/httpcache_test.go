@@ -0,0 +1,41 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   bool
+	}{
+		{"matching etag", "If-None-Match", `"v1"`, true},
+		{"weakened etag", "If-None-Match", `W/"v1"`, true},
+		{"one of many", "If-None-Match", `"v0", "v1"`, true},
+		{"stale etag", "If-None-Match", `"v0"`, false},
+		{"not modified since", "If-Modified-Since", modified.Format(http.TimeFormat), true},
+		{"modified since", "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat), false},
+		{"no validators", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			if got := NotModified(w, r, `"v1"`, modified); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if tt.want && w.Code != http.StatusNotModified {
+				t.Errorf("got status %d, want 304", w.Code)
+			}
+		})
+	}
+}
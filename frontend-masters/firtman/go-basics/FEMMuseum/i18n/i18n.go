@@ -0,0 +1,217 @@
+// Package i18n picks the language exhibitions are shown in and fills in
+// their translated text. Languages are BCP 47 tags like "pt-BR"; a missing
+// translation falls back to the more general tag, "pt", and then to the
+// English the exhibitions are written in.
+package i18n
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// Base is the language of Exhibition.Title and Description.
+const Base = "en"
+
+// Canonical returns tag in its usual case, like "pt-BR" for "PT-br", and
+// whether it's well formed: a language of 2 or 3 letters followed by
+// subtags of up to 8 letters or digits.
+func Canonical(tag string) (string, bool) {
+	subtags := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	if len(subtags[0]) < 2 || len(subtags[0]) > 3 || !letters(subtags[0]) {
+		return "", false
+	}
+	for i, subtag := range subtags {
+		if subtag == "" || len(subtag) > 8 || !alphanumeric(subtag) {
+			return "", false
+		}
+		switch {
+		case i == 0:
+			subtags[i] = strings.ToLower(subtag)
+		case len(subtag) == 2 && letters(subtag):
+			subtags[i] = strings.ToUpper(subtag) // region
+		case len(subtag) == 4 && letters(subtag):
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:]) // script
+		default:
+			subtags[i] = strings.ToLower(subtag)
+		}
+	}
+	return strings.Join(subtags, "-"), true
+}
+
+func letters(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func alphanumeric(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Fallbacks lists the languages text in tag is looked up in, most specific
+// first and ending with Base: "pt-BR", "pt", "en".
+func Fallbacks(tag string) []string {
+	chain := parents(tag)
+	if !slices.Contains(chain, Base) {
+		chain = append(chain, Base)
+	}
+	return chain
+}
+
+// parents lists tag and the more general tags it's part of.
+func parents(tag string) []string {
+	var chain []string
+	for tag != "" {
+		chain = append(chain, tag)
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return chain
+}
+
+// Accepted returns the languages of an Accept-Language header, preferred
+// first. Those refused with q=0 and the "*" wildcard are left out.
+func Accepted(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var list []weighted
+	for _, item := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		canonical, ok := Canonical(strings.TrimSpace(tag))
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			list = append(list, weighted{canonical, q})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+	tags := make([]string, len(list))
+	for i, w := range list {
+		tags[i] = w.tag
+	}
+	return tags
+}
+
+// Requested reports whether r asks for a language, with ?lang= or an
+// Accept-Language header.
+func Requested(r *http.Request) bool {
+	return r.URL.Query().Has("lang") || r.Header.Get("Accept-Language") != ""
+}
+
+// Negotiate picks the language of available, which are translated ones,
+// to answer r in: the one ?lang= names, else the most preferred of
+// Accept-Language that's available, as it is or more general. It's Base
+// when none is, and a ?lang= that isn't a language tag is ignored.
+func Negotiate(r *http.Request, available []string) string {
+	wanted := Accepted(r.Header.Get("Accept-Language"))
+	if tag, ok := Canonical(r.URL.Query().Get("lang")); ok {
+		wanted = []string{tag}
+	}
+	for _, tag := range wanted {
+		for _, candidate := range parents(tag) {
+			if candidate == Base || slices.Contains(available, candidate) {
+				return candidate
+			}
+		}
+	}
+	return Base
+}
+
+// Languages lists the languages some of the exhibitions are translated to.
+func Languages(list []data.Exhibition) []string {
+	var languages []string
+	for _, e := range list {
+		for tag := range e.Translations {
+			if !slices.Contains(languages, tag) {
+				languages = append(languages, tag)
+			}
+		}
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// Localize returns e with its Title and Description in lang, each one
+// falling back on its own. The translations stay as they are.
+func Localize(e data.Exhibition, lang string) data.Exhibition {
+	e, _, _ = localize(e, lang)
+	return e
+}
+
+// localize also reports which fields were translated.
+func localize(e data.Exhibition, lang string) (_ data.Exhibition, title, description bool) {
+	for _, tag := range Fallbacks(lang) {
+		if tag == Base {
+			break
+		}
+		t := e.Translations[tag]
+		if !title && t.Title != "" {
+			e.Title, title = t.Title, true
+		}
+		if !description && t.Description != "" {
+			e.Description, description = t.Description, true
+		}
+	}
+	return e, title, description
+}
+
+// Missing is an exhibition shown partly in English to visitors asking for
+// Language.
+type Missing struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Language string   `json:"language"`
+	Fields   []string `json:"fields"` // Title, Description or both
+}
+
+// MissingTranslations lists the exhibitions of list missing text in each
+// of languages, going through Fallbacks like Localize does.
+func MissingTranslations(list []data.Exhibition, languages []string) []Missing {
+	missing := []Missing{}
+	for _, lang := range languages {
+		if lang == Base {
+			continue
+		}
+		for _, e := range list {
+			_, title, description := localize(e, lang)
+			var fields []string
+			if !title {
+				fields = append(fields, "Title")
+			}
+			if !description {
+				fields = append(fields, "Description")
+			}
+			if len(fields) > 0 {
+				missing = append(missing, Missing{ID: e.ID, Title: e.Title, Language: lang, Fields: fields})
+			}
+		}
+	}
+	return missing
+}
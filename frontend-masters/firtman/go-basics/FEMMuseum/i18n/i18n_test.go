@@ -0,0 +1,98 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+func TestCanonical(t *testing.T) {
+	for tag, want := range map[string]string{
+		"pt-br":      "pt-BR",
+		"EN":         "en",
+		"zh-hant-TW": "zh-Hant-TW",
+		"es_419":     "es-419",
+	} {
+		if got, ok := Canonical(tag); !ok || got != want {
+			t.Errorf("Canonical(%q) = %q, %v; want %q", tag, got, ok, want)
+		}
+	}
+	for _, tag := range []string{"", "*", "p", "pt-", "english", "pt-<b>"} {
+		if _, ok := Canonical(tag); ok {
+			t.Errorf("%q is accepted", tag)
+		}
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	if got := Fallbacks("pt-BR"); !slices.Equal(got, []string{"pt-BR", "pt", "en"}) {
+		t.Errorf("got %q", got)
+	}
+	if got := Fallbacks("en-GB"); !slices.Equal(got, []string{"en-GB", "en"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	available := []string{"de", "pt"}
+	for _, test := range []struct {
+		query, header, want string
+	}{
+		{"", "", "en"},
+		{"", "pt-BR,pt;q=0.9,en;q=0.8", "pt"},
+		// Preference is by quality, then by order.
+		{"", "fr;q=0.5, de;q=0.9", "de"},
+		// A language that isn't available doesn't fall back to English
+		// before the next one is tried.
+		{"", "fr-CA, de", "de"},
+		{"", "fr, en;q=0.5, de;q=0.1", "en"},
+		{"", "de;q=0, pt;q=0.1", "pt"},
+		{"?lang=pt-br", "de", "pt"},
+		{"?lang=fr", "de", "en"},
+		{"?lang=%3Cb%3E", "de", "de"},
+	} {
+		r := httptest.NewRequest("GET", "/api/exhibitions"+test.query, nil)
+		if test.header != "" {
+			r.Header.Set("Accept-Language", test.header)
+		}
+		if got := Negotiate(r, available); got != test.want {
+			t.Errorf("%q with %q: got %s, want %s", test.query, test.header, got, test.want)
+		}
+	}
+}
+
+func TestLocalizeFallsBackByField(t *testing.T) {
+	e := data.Exhibition{
+		ID:          "1",
+		Title:       "Amber",
+		Description: "Fossil resin",
+		Translations: map[string]data.Translation{
+			"pt":    {Title: "Âmbar", Description: "Resina fóssil"},
+			"pt-BR": {Title: "Âmbar do Brasil"},
+			"de":    {Title: "Bernstein"},
+		},
+	}
+	localized := Localize(e, "pt-BR")
+	if localized.Title != "Âmbar do Brasil" || localized.Description != "Resina fóssil" {
+		t.Errorf("got %q, %q", localized.Title, localized.Description)
+	}
+	if e.Title != "Amber" {
+		t.Error("the original was changed")
+	}
+
+	missing := MissingTranslations([]data.Exhibition{e}, []string{"pt-BR", "de", "fr"})
+	want := []Missing{
+		{ID: "1", Title: "Amber", Language: "de", Fields: []string{"Description"}},
+		{ID: "1", Title: "Amber", Language: "fr", Fields: []string{"Title", "Description"}},
+	}
+	if len(missing) != len(want) {
+		t.Fatalf("got %+v", missing)
+	}
+	for i := range want {
+		if missing[i].Language != want[i].Language || !slices.Equal(missing[i].Fields, want[i].Fields) {
+			t.Errorf("got %+v, want %+v", missing[i], want[i])
+		}
+	}
+}
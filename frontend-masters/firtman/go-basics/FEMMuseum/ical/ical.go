@@ -0,0 +1,223 @@
+// Package ical writes exhibition schedules as an iCalendar (RFC 5545) feed.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+const (
+	dateFormat    = "20060102"
+	localFormat   = "20060102T150405"
+	utcFormat     = "20060102T150405Z"
+	maxLineOctets = 75
+	maxZoneYears  = 10 // years of time zone transitions written at most
+	uidDomain     = "museum"
+	productID     = "-//Frontend Masters//Museum//EN"
+)
+
+var byDay = map[string]string{
+	"sunday": "SU", "monday": "MO", "tuesday": "TU", "wednesday": "WE",
+	"thursday": "TH", "friday": "FR", "saturday": "SA",
+}
+
+type Options struct {
+	Name     string // calendar name shown by clients
+	BaseURL  string // prefix of the exhibition page links, like "https://museum.example"
+	Location *time.Location
+	Now      time.Time // DTSTAMP, and where open-ended weekly hours start
+}
+
+// Write writes every exhibition with a schedule as a calendar. The run of
+// an exhibition is an all-day event and each line of its weekly hours a
+// recurring event ending on its closing day, with closures excluded.
+func Write(w io.Writer, list []data.Exhibition, opts Options) error {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if opts.Name == "" {
+		opts.Name = "Exhibitions"
+	}
+	cw := &writer{w: bufio.NewWriter(w), loc: opts.Location, stamp: opts.Now.UTC().Format(utcFormat)}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + productID)
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escape(opts.Name))
+	cw.line("X-WR-TIMEZONE:" + opts.Location.String())
+
+	var scheduled []data.Exhibition
+	for _, e := range list {
+		if e.Schedule != nil {
+			scheduled = append(scheduled, e)
+		}
+	}
+	today := opts.Now.In(opts.Location)
+	cw.timezone(zoneYears(scheduled, today))
+	for _, e := range scheduled {
+		cw.exhibition(e, opts.BaseURL, today)
+	}
+	cw.line("END:VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+type writer struct {
+	w     *bufio.Writer
+	loc   *time.Location
+	stamp string
+	err   error
+}
+
+// line writes one content line, folded to 75 octets as the RFC asks.
+// Continuation lines start with a space, which counts towards their length.
+func (cw *writer) line(s string) {
+	if cw.err != nil {
+		return
+	}
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, cw.err = cw.w.WriteString(s[:cut] + "\r\n "); cw.err != nil {
+			return
+		}
+		s = s[cut:]
+		limit = maxLineOctets - 1
+	}
+	_, cw.err = cw.w.WriteString(s + "\r\n")
+}
+
+func (cw *writer) exhibition(e data.Exhibition, baseURL string, today time.Time) {
+	s := e.Schedule
+	opens, hasOpens := cw.date(s.Opens)
+	closes, hasCloses := cw.date(s.Closes)
+
+	if hasOpens || hasCloses {
+		summary := e.Title
+		start, end := opens, closes.AddDate(0, 0, 1)
+		switch {
+		case !hasCloses:
+			summary += " opens"
+			end = opens.AddDate(0, 0, 1)
+		case !hasOpens:
+			summary += " closes"
+			start = closes
+		}
+		cw.line("BEGIN:VEVENT")
+		cw.common(e, "run", summary, baseURL)
+		cw.line("DTSTART;VALUE=DATE:" + start.Format(dateFormat))
+		cw.line("DTEND;VALUE=DATE:" + end.Format(dateFormat))
+		cw.line("TRANSP:TRANSPARENT")
+		cw.line("END:VEVENT")
+	}
+
+	from := opens
+	if !hasOpens {
+		from = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, cw.loc)
+	}
+	for i, h := range s.Hours {
+		day, ok := byDay[strings.ToLower(h.Day)]
+		if !ok {
+			continue
+		}
+		first := from
+		for data.Weekdays[first.Weekday()] != strings.ToLower(h.Day) {
+			first = first.AddDate(0, 0, 1)
+		}
+		if hasCloses && first.After(closes) {
+			continue
+		}
+		start, okStart := cw.at(first, h.Open)
+		end, okEnd := cw.at(first, h.Close)
+		if !okStart || !okEnd {
+			continue
+		}
+		tzid := ";TZID=" + cw.loc.String()
+
+		cw.line("BEGIN:VEVENT")
+		cw.common(e, fmt.Sprintf("hours-%d", i), e.Title, baseURL)
+		cw.line("DTSTART" + tzid + ":" + start.Format(localFormat))
+		cw.line("DTEND" + tzid + ":" + end.Format(localFormat))
+		rule := "RRULE:FREQ=WEEKLY;BYDAY=" + day
+		if hasCloses {
+			// UNTIL has to be in UTC when DTSTART has a time zone.
+			last := closes.AddDate(0, 0, 1).Add(-time.Second)
+			rule += ";UNTIL=" + last.UTC().Format(utcFormat)
+		}
+		cw.line(rule)
+		var excluded []string
+		for _, closure := range s.Closures {
+			d, ok := cw.date(closure)
+			if !ok || d.Weekday() != first.Weekday() || d.Before(first) || (hasCloses && d.After(closes)) {
+				continue
+			}
+			if t, ok := cw.at(d, h.Open); ok {
+				excluded = append(excluded, t.Format(localFormat))
+			}
+		}
+		if len(excluded) > 0 {
+			slices.Sort(excluded)
+			cw.line("EXDATE" + tzid + ":" + strings.Join(slices.Compact(excluded), ","))
+		}
+		cw.line("END:VEVENT")
+	}
+}
+
+func (cw *writer) common(e data.Exhibition, kind, summary, baseURL string) {
+	cw.line("UID:" + e.ID + "-" + kind + "@" + uidDomain)
+	cw.line("DTSTAMP:" + cw.stamp)
+	cw.line("SUMMARY:" + escape(summary))
+	if e.Description != "" {
+		cw.line("DESCRIPTION:" + escape(e.Description))
+	}
+	if baseURL != "" && e.Slug != "" {
+		cw.line("URL:" + strings.TrimSuffix(baseURL, "/") + "/exhibitions/" + e.Slug)
+	}
+}
+
+// date parses a schedule date as midnight in the museum's time zone.
+func (cw *writer) date(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(data.DateFormat, value, cw.loc)
+	return t, err == nil
+}
+
+func (cw *writer) at(day time.Time, clock string) (time.Time, bool) {
+	t, err := time.Parse(data.TimeFormat, clock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, cw.loc), true
+}
+
+// escape escapes a TEXT value. Every kind of line break becomes \n, since a
+// lone CR would end the content line for some readers.
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,81 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+func TestWriteSchedules(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	list := []data.Exhibition{
+		{ID: "abc", Slug: "tides", Title: "Tides, waves; and \\ currents", Description: "Two\nlines", Schedule: &data.Schedule{
+			Opens:    "2024-03-01",
+			Closes:   "2024-06-30",
+			Hours:    []data.OpeningHours{{Day: "saturday", Open: "10:00", Close: "18:00"}},
+			Closures: []string{"2024-05-04", "2024-05-05"},
+		}},
+		{ID: "unscheduled", Title: "Not in the calendar"},
+	}
+	var b strings.Builder
+	err = Write(&b, list, Options{
+		BaseURL:  "https://museum.example",
+		Location: paris,
+		Now:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		`SUMMARY:Tides\, waves\; and \\ currents` + "\r\n",
+		"DESCRIPTION:Two\\nlines\r\n",
+		"DTSTART;VALUE=DATE:20240301\r\nDTEND;VALUE=DATE:20240701\r\n",
+		"DTSTART;TZID=Europe/Paris:20240302T100000\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=SA;UNTIL=20240630T215959Z\r\n",
+		"EXDATE;TZID=Europe/Paris:20240504T100000\r\n", // the sunday closure isn't a saturday
+		"URL:https://museum.example/exhibitions/tides\r\n",
+		"DTSTAMP:20240102T030405Z\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20240331T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Not in the calendar") {
+		t.Error("an exhibition without a schedule is in the calendar")
+	}
+}
+
+func TestLineBreaksAreEscaped(t *testing.T) {
+	var b strings.Builder
+	Write(&b, []data.Exhibition{{ID: "x", Title: "Amber", Description: "One\rtwo\r\nthree\nfour", Schedule: &data.Schedule{Opens: "2024-01-01"}}}, Options{})
+	out := b.String()
+	if !strings.Contains(out, `DESCRIPTION:One\ntwo\nthree\nfour`+"\r\n") {
+		t.Errorf("the line breaks of the description aren't escaped in\n%s", out)
+	}
+	if strings.Count(out, "\r") != strings.Count(out, "\r\n") {
+		t.Errorf("a CR doesn't end a line in\n%q", out)
+	}
+}
+
+func TestLinesAreFolded(t *testing.T) {
+	var b strings.Builder
+	long := strings.Repeat("é", 100)
+	Write(&b, []data.Exhibition{{ID: "x", Title: long, Schedule: &data.Schedule{Opens: "2024-01-01"}}}, Options{})
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "SUMMARY:"+long+" opens\r\n") {
+		t.Error("the folded summary doesn't unfold to the title")
+	}
+}
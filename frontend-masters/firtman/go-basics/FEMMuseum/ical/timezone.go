@@ -0,0 +1,82 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// zoneYears returns the years the calendar needs time zone definitions
+// for: from the earliest opening day to the latest closing day, or next year
+// for open-ended schedules.
+func zoneYears(list []data.Exhibition, today time.Time) (from, to int) {
+	from, to = today.Year(), today.Year()+1
+	for _, e := range list {
+		if t, err := time.Parse(data.DateFormat, e.Schedule.Opens); err == nil {
+			from = min(from, t.Year())
+		}
+		if t, err := time.Parse(data.DateFormat, e.Schedule.Closes); err == nil {
+			to = max(to, t.Year())
+		}
+	}
+	if to-from >= maxZoneYears {
+		from = to - maxZoneYears + 1
+	}
+	return from, to
+}
+
+// timezone writes the VTIMEZONE the TZIDs refer to, with every offset
+// change between the start of year from and the end of year to.
+func (cw *writer) timezone(from, to int) {
+	start := time.Date(from, time.January, 1, 0, 0, 0, 0, cw.loc)
+	end := time.Date(to+1, time.January, 1, 0, 0, 0, 0, cw.loc)
+
+	cw.line("BEGIN:VTIMEZONE")
+	cw.line("TZID:" + cw.loc.String())
+	wrote := false
+	for t := start; ; {
+		_, next := t.ZoneBounds()
+		if next.IsZero() || !next.Before(end) {
+			break
+		}
+		cw.observance(next.Add(-time.Second), next)
+		wrote = true
+		t = next
+	}
+	if !wrote {
+		// No changes in that range, so the offset holds from the epoch on.
+		cw.observance(start, time.Date(1970, time.January, 1, 0, 0, 0, 0, cw.loc))
+	}
+	cw.line("END:VTIMEZONE")
+}
+
+// observance writes the change from the offset in effect at before to the
+// one in effect at onset.
+func (cw *writer) observance(before, onset time.Time) {
+	_, fromOffset := before.Zone()
+	name, toOffset := onset.Zone()
+	kind := "STANDARD"
+	if onset.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	cw.line("BEGIN:" + kind)
+	// The onset is written in the local time that was in effect before it.
+	cw.line("DTSTART:" + onset.In(time.FixedZone("", fromOffset)).Format(localFormat))
+	cw.line("TZOFFSETFROM:" + offset(fromOffset))
+	cw.line("TZOFFSETTO:" + offset(toOffset))
+	cw.line("TZNAME:" + escape(name))
+	cw.line("END:" + kind)
+}
+
+func offset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	s := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
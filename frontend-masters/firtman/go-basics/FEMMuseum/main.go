@@ -1,40 +1,411 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"log/slog"
 	"net/http"
-	"text/template"
+	"net/netip"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	_ "time/tzdata" // schedules must work on hosts without a zoneinfo database
 
+	"frontendmasters.com/go/museum/admin"
 	"frontendmasters.com/go/museum/api"
+	"frontendmasters.com/go/museum/auth"
+	"frontendmasters.com/go/museum/config"
 	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/events"
+	"frontendmasters.com/go/museum/gallery"
+	"frontendmasters.com/go/museum/httpcache"
+	"frontendmasters.com/go/museum/middleware"
+	"frontendmasters.com/go/museum/render"
+	"frontendmasters.com/go/museum/search"
+	"frontendmasters.com/go/museum/static"
+	"frontendmasters.com/go/museum/stats"
+	"frontendmasters.com/go/museum/tickets"
+	"frontendmasters.com/go/museum/transfer"
+	"frontendmasters.com/go/museum/validation"
+	"frontendmasters.com/go/museum/webhooks"
 )
 
+// ready is set once the exhibition store is loaded and cleared again when
+// the server starts shutting down.
+var ready atomic.Bool
+
+// adminUI is set by load, before the server is ready.
+var adminUI *admin.Admin
+
 func handleHello(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hello from a Go program!!!"))
 }
 
-func handleTemplate(w http.ResponseWriter, r *http.Request) {
-	html, err := template.ParseFiles("templates/index.tmpl")
+// handleHealth reports that the process is up and serving HTTP.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// handleReady reports whether the server can take traffic.
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// requireReady answers 503 until the store is loaded.
+func requireReady(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "The museum is starting, try again shortly", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func openRepository(path string) (data.Repository, func() error, error) {
+	if path == "" {
+		return data.NewMemoryRepository(), func() error { return nil }, nil
+	}
+	repo, err := data.OpenFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, repo.Close, nil
+}
+
+// load opens the store and sets up everything reading from it.
+func load(cfg config.Config, trusted []netip.Prefix, broker *events.Broker, public fs.FS) (closeRepo func() error, err error) {
+	repo, closeRepo, err := openRepository(cfg.DataFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening the exhibition store: %w", err)
+	}
+	if cfg.DataFile != "" {
+		history, err := data.OpenHistory(cfg.DataFile + ".history")
+		if err != nil {
+			closeRepo()
+			return nil, fmt.Errorf("opening the revision history: %w", err)
+		}
+		data.UseHistory(history)
+		closeStore := closeRepo
+		closeRepo = func() error {
+			return errors.Join(closeStore(), history.Close())
+		}
+	}
+	if err := data.Seed(repo); err != nil {
+		closeRepo()
+		return nil, fmt.Errorf("loading the initial exhibitions: %w", err)
+	}
+	data.Use(repo)
+
+	galleryFS, err := fs.Sub(public, "gallery")
+	if err != nil {
+		closeRepo()
+		return nil, err
+	}
+	validator := validation.New(galleryFS)
+	api.UseValidator(validator)
+	images := gallery.NewStore(cfg.UploadDir, "/gallery")
+	api.UseGallery(images)
+
+	authenticator, err := auth.Open(cfg.AuthDir)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Internal Server Error"))
+		closeRepo()
+		return nil, fmt.Errorf("setting up authentication: %w", err)
+	}
+	stopReloading := authenticator.Keys.ReloadEvery(auth.DefaultReloadInterval, func(err error) {
+		log.Printf("Couldn't reload the API keys: %v", err)
+	})
+	closeStore := closeRepo
+	closeRepo = func() error {
+		stopReloading()
+		return errors.Join(closeStore(), authenticator.Audit.Close())
+	}
+	api.UseAuth(authenticator)
+	adminUI = admin.New(admin.Options{
+		Renderer:      renderer,
+		Auth:          authenticator,
+		Validator:     validator,
+		Gallery:       galleryFS,
+		Images:        images,
+		SecureCookies: !cfg.Dev,
+	})
+
+	index := search.NewIndex()
+	data.Subscribe(index.Apply)
+	index.Build(data.GetAll())
+	api.UseSearchIndex(index)
+
+	data.Subscribe(broker.Publish)
+	api.UseEvents(broker)
+
+	api.UseTrustedProxies(trusted)
+	// Like exhibitions, tickets only last as long as the process without
+	// a data file.
+	if cfg.DataFile != "" {
+		booker, err := tickets.OpenBooker(cfg.DataFile + ".tickets")
+		if err != nil {
+			closeRepo()
+			return nil, fmt.Errorf("opening the ticket journal: %w", err)
+		}
+		api.UseTickets(booker)
+		closeStore := closeRepo
+		closeRepo = func() error {
+			return errors.Join(closeStore(), booker.Close())
+		}
+	}
+
+	views = stats.NewRecorder(trusted)
+	if cfg.DataFile != "" {
+		if views, err = stats.OpenRecorder(cfg.DataFile+".stats", stats.DefaultFlushInterval, trusted); err != nil {
+			closeRepo()
+			return nil, fmt.Errorf("opening the view counts: %w", err)
+		}
+		closeStore := closeRepo
+		closeRepo = func() error {
+			return errors.Join(closeStore(), views.Close())
+		}
+	}
+	api.UseStats(views)
+
+	// Webhook deliveries outlive the process only when the store does.
+	hooks := webhooks.New(webhooks.Options{})
+	if cfg.DataFile != "" {
+		if hooks, err = webhooks.Open(cfg.DataFile+".webhooks", webhooks.Options{}); err != nil {
+			closeRepo()
+			return nil, fmt.Errorf("opening the webhook queue: %w", err)
+		}
+	}
+	hooks.Track(data.GetAll())
+	data.Subscribe(hooks.Notify)
+	hooks.Start()
+	api.UseWebhooks(hooks)
+	closeStore = closeRepo
+	closeRepo = func() error {
+		// Sending stops first, so no attempt is left half recorded.
+		return errors.Join(hooks.Close(), closeStore())
+	}
+
+	for _, file := range cfg.ImportFiles {
+		if err := importFile(file, validator, images); err != nil {
+			closeRepo()
+			return nil, fmt.Errorf("importing %s: %w", file, err)
+		}
+	}
+	if err := checkImages(galleryFS); err != nil {
+		closeRepo()
+		return nil, err
+	}
+	if cfg.DataJSON != "" {
+		mirror, err := transfer.StartMirror(cfg.DataJSON)
+		if err != nil {
+			closeRepo()
+			return nil, fmt.Errorf("writing %s: %w", cfg.DataJSON, err)
+		}
+		data.Subscribe(mirror.Notify)
+	}
+	return closeRepo, nil
+}
+
+// checkImages makes sure every exhibition's image is in the gallery, which
+// catches a binary built without an image the store refers to.
+func checkImages(gallery fs.FS) error {
+	var missing []string
+	for _, e := range data.GetAll() {
+		if _, err := fs.Stat(gallery, e.Image); err != nil {
+			missing = append(missing, fmt.Sprintf("%q (%s)", e.Image, e.Title))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("images missing from the gallery: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// handleDataJSON serves the exhibitions the way the static frontend reads
+// them, straight from the store.
+func handleDataJSON(w http.ResponseWriter, r *http.Request) {
+	if httpcache.NotModified(w, r, `"`+data.Version()+`"`, data.LastModified()) {
 		return
 	}
-	html.Execute(w, data.GetAll())
+	w.Header().Set("Content-Type", "application/json")
+	transfer.WriteJSON(w, data.GetAll())
 }
 
-func main() {
+func handleAdmin(w http.ResponseWriter, r *http.Request) {
+	adminUI.ServeHTTP(w, r)
+}
+
+func importFile(path string, validator *validation.Validator, images *gallery.Store) error {
+	records, err := transfer.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ctx := data.WithAuthor(context.Background(), "import "+filepath.Base(path))
+	report, err := transfer.Merge(ctx, records, transfer.Options{
+		MatchBy:  transfer.MatchByTitle,
+		Validate: validator.Exhibition,
+		Variants: images.Variants,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Imported %s: %d created, %d updated, %d unchanged, %d conflicts, %d invalid",
+		path, len(report.Created), len(report.Updated), len(report.Unchanged), len(report.Conflicts), len(report.Invalid))
+	for _, outcome := range append(report.Conflicts, report.Invalid...) {
+		log.Printf("Import %s row %d (%q): %s", path, outcome.Row, outcome.Title, outcome.Reason)
+	}
+	return nil
+}
+
+func routes(cfg config.Config, trusted []netip.Prefix, logger *slog.Logger, assets *static.Assets, public fs.FS) http.Handler {
+	apiRoutes := http.NewServeMux()
+	api.Register(apiRoutes)
+	rateLimit := middleware.RateLimit(middleware.RateLimitOptions{
+		Read:           middleware.Limit{Requests: cfg.ReadsPerMinute, Period: time.Minute},
+		Write:          middleware.Limit{Requests: cfg.WritesPerMinute, Period: time.Minute},
+		TrustedProxies: trusted,
+		Identify:       api.Identify,
+	})
+	cors := middleware.CORS(middleware.CORSOptions{
+		AllowedOrigins: cfg.CORSOrigins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", middleware.RequestIDHeader},
+		ExposedHeaders: []string{"Location", "Link", "X-Total-Count", middleware.RequestIDHeader, "Retry-After", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+		MaxAge:         time.Hour,
+	})
+
+	app := http.NewServeMux()
+	app.HandleFunc("/hello", handleHello)
+	app.HandleFunc("/template", handleTemplate)
+	app.HandleFunc("GET /exhibitions/{slug}", handleExhibition)
+	// Preflights are answered before counting, since browsers send them on
+	// their own.
+	app.Handle("/api/", cors(api.Authenticate(rateLimit(apiRoutes))))
+
+	app.HandleFunc("GET /gallery/data.json", handleDataJSON)
+	admin := rateLimit(http.HandlerFunc(handleAdmin))
+	app.Handle("/admin", admin)
+	app.Handle("/admin/", admin)
+
+	files := http.FileServer(http.FS(public))
+	app.Handle("/", assets.Handler(static.Negotiate(public, files)))
+
+	cacheControl := httpcache.Control([]httpcache.Rule{
+		{Prefix: "/api/", Value: httpcache.NoCache},
+		{Prefix: "/template", Value: httpcache.NoCache},
+		{Prefix: "/exhibitions/", Value: httpcache.NoCache},
+		{Prefix: "/gallery/data.json", Value: httpcache.NoCache},
+		{Prefix: "/gallery/", Value: "public, max-age=86400"},
+		{Prefix: "/admin", Value: "no-store"},
+		{Prefix: "/", Value: "public, max-age=300"},
+	})
+
 	server := http.NewServeMux()
-	server.HandleFunc("/hello", handleHello)
-	server.HandleFunc("/template", handleTemplate)
-	server.HandleFunc("/api/exhibitions", api.Get)
-	server.HandleFunc("/api/exhibitions/new", api.Post)
+	server.HandleFunc("GET /healthz", handleHealth)
+	server.HandleFunc("GET /readyz", handleReady)
+	server.Handle("/", requireReady(cacheControl(app)))
+	return middleware.Chain(server,
+		middleware.RequestID,
+		middleware.AccessLog(logger),
+		middleware.Recover(logger),
+		middleware.Gzip,
+	)
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdmin(os.Args[2:], os.Stdout, os.Stderr))
+	}
 
-	fs := http.FileServer(http.Dir("./public"))
-	server.Handle("/", fs)
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	slog.SetDefault(logger)
 
-	err := http.ListenAndServe(":3333", server)
-	if err == nil {
-		fmt.Println("Error while opening the server")
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		log.Fatalf("Invalid time zone: %v", err)
+	}
+	data.SetLocation(location)
+	trusted, err := middleware.ParsePrefixes(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	// Uploads can't go into the embedded site, so they're mounted into it.
+	public := static.Mount(siteDir(cfg.StaticDir, "public"), "gallery/"+gallery.UploadDir, os.DirFS(cfg.UploadDir))
+	assets, err := static.Fingerprint(public)
+	if err != nil {
+		log.Fatalf("Couldn't read the static files: %v", err)
+	}
+	renderer, err = render.New(siteDir(cfg.TemplateDir, "templates"), cfg.Dev, template.FuncMap{
+		"asset": assets.Path,
+	})
+	if err != nil {
+		log.Fatalf("Couldn't parse the templates: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           routes(cfg, trusted, logger, assets, public),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	// Event streams never go idle, so they have to be ended for Shutdown
+	// to finish.
+	broker := events.NewBroker(events.DefaultLogSize)
+	server.RegisterOnShutdown(broker.Close)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", cfg.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// The server already answers /healthz while a large store is replayed.
+	closeRepo, err := load(cfg, trusted, broker, public)
+	if err != nil {
+		log.Fatalf("Couldn't start: %v", err)
+	}
+	ready.Store(true)
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			closeRepo()
+			log.Fatalf("Error while running the server: %v", err)
+		}
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Print("Shutting down, waiting for in-flight requests")
+	ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Couldn't drain every request: %v", err)
+	}
+	if err := closeRepo(); err != nil {
+		log.Printf("Couldn't close the exhibition store: %v", err)
 	}
 }
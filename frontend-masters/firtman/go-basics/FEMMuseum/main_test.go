@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"frontendmasters.com/go/museum/config"
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/static"
+)
+
+// dom is just enough of a browser to run public/script.js: elements record
+// their text and children, and any markup assigned to them.
+const dom = `
+const markup = [];
+class Element {
+    constructor(tag) { this.tag = tag; this.children = []; this.textContent = ""; }
+    set innerHTML(html) { markup.push(html); }
+    append(...children) { this.children.push(...children); }
+}
+const main = new Element("main");
+globalThis.document = {
+    createElement: tag => new Element(tag),
+    querySelector: selector => selector === "main" ? main : null,
+};
+globalThis.PerformanceObserver = class { observe() {} };
+let ready;
+globalThis.window = { addEventListener: (type, listener) => { ready = listener; } };
+globalThis.fetch = async () => ({ json: async () => JSON.parse(process.env.DATA_JSON) });
+`
+
+const run = `
+ready().then(() => console.log(JSON.stringify({ markup, articles: main.children })));
+`
+
+func TestScriptShowsTitlesAsText(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("running public/script.js needs node")
+	}
+	script, err := os.ReadFile("public/script.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data.Use(data.NewMemoryRepository())
+	defer data.Use(data.NewMemoryRepository())
+	title := `<img src=x onerror="alert(1)"><script>alert(2)</script>`
+	data.Add(context.Background(), data.Exhibition{Title: title, Description: "<b>bold</b>", Image: "amber.png"})
+	w := httptest.NewRecorder()
+	handleDataJSON(w, httptest.NewRequest("GET", "/gallery/data.json", nil))
+
+	cmd := exec.Command(node, "-e", dom+string(script)+run)
+	cmd.Env = append(os.Environ(), "DATA_JSON="+w.Body.String())
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	type element struct {
+		Tag         string    `json:"tag"`
+		TextContent string    `json:"textContent"`
+		Alt         string    `json:"alt"`
+		Children    []element `json:"children"`
+	}
+	var result struct {
+		Markup   []string  `json:"markup"`
+		Articles []element `json:"articles"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if len(result.Markup) > 0 {
+		t.Errorf("markup was assigned: %q", result.Markup)
+	}
+	if len(result.Articles) != 1 || len(result.Articles[0].Children) != 3 {
+		t.Fatalf("got %s", out)
+	}
+	children := result.Articles[0].Children
+	if children[0].TextContent != title || children[1].TextContent != "<b>bold</b>" || children[2].Alt != title {
+		t.Errorf("got %s, want the title and description as text", out)
+	}
+}
+
+func TestHoldsCountAgainstTheWriteBudget(t *testing.T) {
+	cfg := config.Default()
+	cfg.WritesPerMinute = 1
+	public := fstest.MapFS{}
+	assets, err := static.Fingerprint(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := routes(cfg, nil, slog.New(slog.DiscardHandler), assets, public)
+	ready.Store(true)
+	defer ready.Store(false)
+
+	codes := make([]int, 2)
+	for i := range codes {
+		r := httptest.NewRequest("POST", "/api/exhibitions/1/holds", strings.NewReader(`{}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		codes[i] = w.Code
+	}
+	if codes[0] == http.StatusTooManyRequests || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("got %v, want the second hold over the budget", codes)
+	}
+}
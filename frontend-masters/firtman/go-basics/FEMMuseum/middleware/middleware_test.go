@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRecoverAnswers500(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), RequestID, Recover(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want 500", rec.Code)
+	}
+	if rec.Header().Get(RequestIDHeader) == "" {
+		t.Error("got no request ID on the response")
+	}
+}
+
+func TestGzipCompressesText(t *testing.T) {
+	h := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"hello":"museum"}`)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got headers %v, want a gzip response", rec.Header())
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(gz)
+	if string(body) != `{"hello":"museum"}` {
+		t.Errorf("got body %q", body)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := time.Date(2024, 4, 6, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+	h := RateLimit(RateLimitOptions{
+		Read:  Limit{Requests: 2, Period: time.Minute},
+		Write: Limit{Requests: 1, Period: time.Minute},
+		Identify: func(r *http.Request) (string, bool) {
+			key := r.Header.Get("X-API-Key")
+			return key, key != ""
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(method, remote, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/exhibitions", nil)
+		req.RemoteAddr = remote + ":1234"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range 2 {
+		if rec := send("GET", "192.0.2.1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("read %d got %d", i, rec.Code)
+		}
+	}
+	rec := send("GET", "192.0.2.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third read got %d", rec.Code)
+	}
+	// A token comes back every 30 seconds.
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After is %q", got)
+	}
+	if rec.Header().Get("RateLimit-Remaining") != "0" || rec.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("got headers %v", rec.Header())
+	}
+
+	// Writes, other addresses and API keys have budgets of their own.
+	if rec := send("POST", "192.0.2.1", ""); rec.Code != http.StatusOK {
+		t.Errorf("write got %d", rec.Code)
+	}
+	if rec := send("GET", "192.0.2.2", ""); rec.Code != http.StatusOK {
+		t.Errorf("read from another address got %d", rec.Code)
+	}
+	if rec := send("GET", "192.0.2.1", "key"); rec.Code != http.StatusOK {
+		t.Errorf("read with a key got %d", rec.Code)
+	}
+
+	clock = clock.Add(30 * time.Second)
+	if rec := send("GET", "192.0.2.1", ""); rec.Code != http.StatusOK {
+		t.Errorf("read after a refill got %d", rec.Code)
+	}
+}
+
+func TestRateLimitDropsIdleBuckets(t *testing.T) {
+	l := newLimiter(Limit{Requests: 10, Period: time.Minute})
+	start := time.Date(2024, 4, 6, 12, 0, 0, 0, time.UTC)
+	for i := range 100 {
+		l.take(strconv.Itoa(i), start)
+	}
+	l.take("late", start.Add(time.Minute))
+	if len(l.buckets) != 1 {
+		t.Errorf("%d buckets left", len(l.buckets))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParsePrefixes([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		remote, forwarded, want string
+	}{
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+		// Only trusted proxies may say who the client is.
+		{"192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{"10.0.0.2:1234", "198.51.100.7", "198.51.100.7"},
+		{"[::1]:1234", "198.51.100.7, 10.0.0.3", "198.51.100.7"},
+		// What the client wrote itself, left of the first untrusted hop,
+		// is ignored.
+		{"10.0.0.2:1234", "203.0.113.9, 198.51.100.7", "198.51.100.7"},
+		{"10.0.0.2:1234", "garbage", "10.0.0.2"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if got := ClientIP(r, trusted).String(); got != test.want {
+			t.Errorf("%s forwarding %q: got %s, want %s", test.remote, test.forwarded, got, test.want)
+		}
+	}
+}
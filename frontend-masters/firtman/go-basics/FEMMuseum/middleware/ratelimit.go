@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// now is the clock buckets fill by; tests replace it.
+var now = time.Now
+
+// Limit allows Requests per Period, all at once at most.
+type Limit struct {
+	Requests int // unlimited when zero
+	Period   time.Duration
+}
+
+type RateLimitOptions struct {
+	// Reads are GET, HEAD and OPTIONS requests; Writes the others. Each
+	// client has a budget of both.
+	Read, Write Limit
+	// TrustedProxies are the proxies whose X-Forwarded-For is believed.
+	TrustedProxies []netip.Prefix
+	// Identify names the client of an authenticated request, which then
+	// has its own budget from any address. Others are told apart by IP.
+	Identify func(*http.Request) (string, bool)
+}
+
+// RateLimit answers 429 to clients going over their budget. Every response
+// tells the budget in RateLimit-* headers.
+func RateLimit(options RateLimitOptions) Middleware {
+	reads := newLimiter(options.Read)
+	writes := newLimiter(options.Write)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := writes
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				l = reads
+			}
+			if l.limit.Requests <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := ""
+			if options.Identify != nil {
+				if client, ok := options.Identify(r); ok {
+					key = "client " + client
+				}
+			}
+			if key == "" {
+				key = "ip " + ClientIP(r, options.TrustedProxies).String()
+			}
+
+			allowed, remaining, retry, reset := l.take(key, now())
+			h := w.Header()
+			h.Set("RateLimit-Policy", strconv.Itoa(l.limit.Requests)+";w="+strconv.Itoa(seconds(l.limit.Period)))
+			h.Set("RateLimit-Limit", strconv.Itoa(l.limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(reset)))
+			if !allowed {
+				h.Set("Retry-After", strconv.Itoa(seconds(retry)))
+				h.Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]any{
+					"type":     "/problems/rate-limited",
+					"title":    "You are sending too many requests",
+					"status":   http.StatusTooManyRequests,
+					"detail":   "try again in " + strconv.Itoa(seconds(retry)) + " seconds",
+					"instance": r.URL.Path,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds rounds d up, so clients never come back too early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// limiter keeps a token bucket per client. A bucket left alone for a
+// Period is full again, the same as a new one, so those are dropped.
+type limiter struct {
+	limit Limit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newLimiter(limit Limit) *limiter {
+	return &limiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// take spends a token of the bucket of key at t. It returns whether there
+// was one, how many are left, when the next one comes and when the bucket
+// is full again.
+func (l *limiter) take(key string, t time.Time) (allowed bool, remaining int, retry, reset time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.Sub(l.swept) >= l.limit.Period {
+		l.sweep(t)
+	}
+	burst := float64(l.limit.Requests)
+	perToken := l.limit.Period / time.Duration(l.limit.Requests)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: t}
+		l.buckets[key] = b
+	}
+	b.tokens = min(burst, b.tokens+float64(t.Sub(b.updated))/float64(perToken))
+	b.updated = t
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		retry = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	reset = time.Duration((burst - b.tokens) * float64(perToken))
+	return allowed, int(b.tokens), retry, reset
+}
+
+// sweep drops the buckets idle for a Period. l.mu must be held.
+func (l *limiter) sweep(t time.Time) {
+	for key, b := range l.buckets {
+		if t.Sub(b.updated) >= l.limit.Period {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = t
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover turns a panicking handler into a 500 response and logs the stack,
+// instead of dropping the connection.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &recorder{ResponseWriter: w}
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				// Handlers use this panic on purpose to abort a response.
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				logger.ErrorContext(r.Context(), "panic while serving request",
+					slog.String("request_id", RequestIDFrom(r.Context())),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Any("panic", err),
+					slog.String("stack", string(debug.Stack())),
+				)
+				// Once the status is out there's nothing we can fix; the
+				// client sees a truncated response.
+				if rec.status == 0 {
+					w.Header().Del("Content-Encoding")
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
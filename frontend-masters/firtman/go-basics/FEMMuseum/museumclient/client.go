@@ -0,0 +1,287 @@
+// Package museumclient is a Go client for the museum's exhibitions API.
+package museumclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// The API speaks the server's own types.
+type (
+	Exhibition    = data.Exhibition
+	Schedule      = data.Schedule
+	TimedEntry    = data.TimedEntry
+	OpeningHours  = data.OpeningHours
+	ImageVariants = data.ImageVariants
+	Translation   = data.Translation
+)
+
+const (
+	defaultRetries   = 2
+	defaultRetryWait = 200 * time.Millisecond
+	maxRetryWait     = 5 * time.Second
+	maxErrorBody     = 64 << 10
+)
+
+type Options struct {
+	// HTTPClient sends the requests; http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// Token is an API key or a token, sent as a bearer credential. Reads
+	// don't need one.
+	Token string
+	// MaxRetries is how many times GET, PUT and DELETE requests are tried
+	// again after a network error or a 429, 502, 503 or 504. 0 means the
+	// default of 2; negative disables retries.
+	MaxRetries int
+	// RetryWait is the first pause between tries, doubled after each one,
+	// unless the server asks for a longer one with Retry-After.
+	RetryWait time.Duration
+	UserAgent string
+}
+
+type Client struct {
+	base *url.URL
+	opts Options
+}
+
+// New returns a client for the server at baseURL, like
+// "https://museum.example".
+func New(baseURL string, opts Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("museumclient: base URL %q must be http or https", baseURL)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultRetries
+	}
+	if opts.RetryWait <= 0 {
+		opts.RetryWait = defaultRetryWait
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "museumclient"
+	}
+	return &Client{base: base, opts: opts}, nil
+}
+
+// ListOptions filter and page GET /api/exhibitions. Zero values are left out.
+type ListOptions struct {
+	Open   *bool
+	Color  string
+	Sort   string // "title" or "-title"
+	Limit  int
+	Cursor string // Page.Next or Page.Prev of an earlier call
+}
+
+type Page struct {
+	Items []Exhibition
+	Total int
+	Next  string // cursor of the next page, empty on the last one
+	Prev  string
+}
+
+func (c *Client) List(ctx context.Context, opts ListOptions) (*Page, error) {
+	query := url.Values{}
+	if opts.Open != nil {
+		query.Set("open", strconv.FormatBool(*opts.Open))
+	}
+	if opts.Color != "" {
+		query.Set("color", opts.Color)
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	}
+	page := &Page{}
+	resp, err := c.do(ctx, http.MethodGet, "/api/exhibitions", query, nil, &page.Items)
+	if err != nil {
+		return nil, err
+	}
+	page.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	page.Next, page.Prev = cursors(resp.Header.Get("Link"))
+	return page, nil
+}
+
+func (c *Client) Get(ctx context.Context, id string) (Exhibition, error) {
+	var e Exhibition
+	_, err := c.do(ctx, http.MethodGet, "/api/exhibitions/"+url.PathEscape(id), nil, nil, &e)
+	return e, err
+}
+
+// Create stores e and returns it with its ID and slug. Create is never
+// retried, since a lost response would otherwise create a duplicate.
+func (c *Client) Create(ctx context.Context, e Exhibition) (Exhibition, error) {
+	var created Exhibition
+	_, err := c.do(ctx, http.MethodPost, "/api/exhibitions", nil, e, &created)
+	return created, err
+}
+
+// Update replaces the exhibition with e.ID.
+func (c *Client) Update(ctx context.Context, e Exhibition) (Exhibition, error) {
+	if e.ID == "" {
+		return Exhibition{}, errors.New("museumclient: Update needs the exhibition ID")
+	}
+	var updated Exhibition
+	_, err := c.do(ctx, http.MethodPut, "/api/exhibitions/"+url.PathEscape(e.ID), nil, e, &updated)
+	return updated, err
+}
+
+func (c *Client) Delete(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/exhibitions/"+url.PathEscape(id), nil, nil, nil)
+	return err
+}
+
+type SearchResult struct {
+	Exhibition Exhibition        `json:"exhibition"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"` // HTML, matches wrapped in <mark>
+}
+
+// Search returns up to limit exhibitions matching q, best first. A limit
+// of 0 uses the server's default.
+func (c *Client) Search(ctx context.Context, q string, limit int) ([]SearchResult, error) {
+	query := url.Values{"q": {q}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var body struct {
+		Results []SearchResult `json:"results"`
+	}
+	_, err := c.do(ctx, http.MethodGet, "/api/search", query, nil, &body)
+	return body.Results, err
+}
+
+// do sends a request, retrying idempotent ones, and decodes a successful
+// response into out when it's not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) (*http.Response, error) {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+	retries := 0
+	if method != http.MethodPost && method != http.MethodPatch {
+		retries = max(c.opts.MaxRetries, 0)
+	}
+
+	wait := c.opts.RetryWait
+	for try := 0; ; try++ {
+		resp, err := c.send(ctx, method, u.String(), body)
+		if try < retries && retryable(resp, err) {
+			pause := wait
+			if resp != nil {
+				pause = max(pause, retryAfter(resp))
+				resp.Body.Close()
+			}
+			wait *= 2
+			select {
+			case <-time.After(min(pause, maxRetryWait)):
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return resp, decodeError(resp)
+		}
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return resp, fmt.Errorf("museumclient: decoding %s %s: %w", method, path, err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+	return c.opts.HTTPClient.Do(req)
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// A canceled or expired context won't get any better.
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter reads a Retry-After header given in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// cursors reads the next and prev cursors out of a Link header.
+func cursors(header string) (next, prev string) {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(target, "<> "))
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(params) {
+		case `rel="next"`:
+			next = u.Query().Get("cursor")
+		case `rel="prev"`:
+			prev = u.Query().Get("cursor")
+		}
+	}
+	return next, prev
+}
@@ -0,0 +1,153 @@
+package museumclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"frontendmasters.com/go/museum/api"
+	"frontendmasters.com/go/museum/auth"
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/search"
+)
+
+// newServer serves the real API over a fresh store and returns an editor
+// key for it.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, string) {
+	t.Helper()
+	data.Use(data.NewMemoryRepository())
+	index := search.NewIndex()
+	data.Subscribe(index.Apply)
+	api.UseSearchIndex(index)
+
+	authenticator, err := auth.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { authenticator.Audit.Close() })
+	api.UseAuth(authenticator)
+	key, _, err := authenticator.Keys.Create("client test", auth.Editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	api.Register(mux)
+	var handler http.Handler = mux
+	if wrap != nil {
+		handler = wrap(mux)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, key
+}
+
+func TestCRUDAndSearch(t *testing.T) {
+	server, key := newServer(t, nil)
+	c, err := New(server.URL, Options{Token: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	created, err := c.Create(ctx, Exhibition{Title: "Chameleons", Description: "Lizards that change color", Image: "chameleon.png", Color: "green"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Slug != "chameleons" {
+		t.Fatalf("got %+v", created)
+	}
+	for _, title := range []string{"Amber", "Basalt"} {
+		if _, err := c.Create(ctx, Exhibition{Title: title, Description: "Rocks", Image: "rock.png"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := c.List(ctx, ListOptions{Sort: "title", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].Title != "Amber" || page.Next == "" {
+		t.Fatalf("got first page %+v", page)
+	}
+	page, err = c.List(ctx, ListOptions{Sort: "title", Limit: 2, Cursor: page.Next})
+	if err != nil || len(page.Items) != 1 || page.Items[0].Title != "Chameleons" || page.Next != "" || page.Prev == "" {
+		t.Fatalf("got second page %+v, %v", page, err)
+	}
+
+	created.Description = "Lizards that blend in"
+	updated, err := c.Update(ctx, created)
+	if err != nil || updated.Description != created.Description {
+		t.Fatalf("got %+v, %v", updated, err)
+	}
+	got, err := c.Get(ctx, created.ID)
+	if err != nil || got.Description != created.Description {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+
+	results, err := c.Search(ctx, "blend", 5)
+	if err != nil || len(results) != 1 || results[0].Exhibition.ID != created.ID {
+		t.Fatalf("got %+v, %v", results, err)
+	}
+
+	if err := c.Delete(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, created.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v after deleting, want ErrNotFound", err)
+	}
+}
+
+func TestErrorsAreDecoded(t *testing.T) {
+	server, key := newServer(t, nil)
+	ctx := context.Background()
+
+	anonymous, _ := New(server.URL, Options{})
+	if _, err := anonymous.Create(ctx, Exhibition{Title: "x"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got %v without a key, want ErrUnauthorized", err)
+	}
+
+	c, _ := New(server.URL, Options{Token: key})
+	_, err := c.Create(ctx, Exhibition{Title: "No description", Image: "x.png", Color: "pink"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrInvalid) {
+		t.Fatalf("got %v, want an invalid fields *Error", err)
+	}
+	fields := map[string]bool{}
+	for _, f := range apiErr.Fields {
+		fields[f.Field] = true
+	}
+	if !fields["Description"] || !fields["Color"] {
+		t.Errorf("got fields %+v", apiErr.Fields)
+	}
+}
+
+func TestIdempotentCallsAreRetried(t *testing.T) {
+	var failures atomic.Int32
+	flaky := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failures.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	server, key := newServer(t, flaky)
+	c, _ := New(server.URL, Options{Token: key, RetryWait: time.Millisecond})
+	ctx := context.Background()
+
+	if _, err := c.List(ctx, ListOptions{}); err != nil {
+		t.Fatalf("a GET failing twice wasn't retried: %v", err)
+	}
+
+	failures.Store(0)
+	_, err := c.Create(ctx, Exhibition{Title: "Once", Description: "only", Image: "x.png"})
+	if !errors.Is(err, ErrUnavailable) || failures.Load() != 1 {
+		t.Errorf("got %v after %d tries, want a single POST", err, failures.Load())
+	}
+}
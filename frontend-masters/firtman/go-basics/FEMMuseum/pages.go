@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/httpcache"
+	"frontendmasters.com/go/museum/i18n"
+	"frontendmasters.com/go/museum/render"
+	"frontendmasters.com/go/museum/stats"
+)
+
+var (
+	renderer *render.Renderer
+	// views counts the exhibition pages viewed; set by load.
+	views *stats.Recorder
+)
+
+// indexPage and exhibitionPage are what the public pages render, with the
+// exhibitions in Lang.
+type indexPage struct {
+	Lang        string
+	Exhibitions []data.Exhibition
+}
+
+type exhibitionPage struct {
+	Lang string
+	data.Exhibition
+}
+
+// pageLanguage negotiates the language of a page out of available.
+func pageLanguage(w http.ResponseWriter, r *http.Request, available []string) string {
+	lang := i18n.Negotiate(r, available)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", lang)
+	return lang
+}
+
+func handleTemplate(w http.ResponseWriter, r *http.Request) {
+	all := data.GetAll()
+	lang := pageLanguage(w, r, i18n.Languages(all))
+	if httpcache.NotModified(w, r, `"`+data.Version()+"-"+lang+`"`, data.LastModified()) {
+		return
+	}
+	page := indexPage{Lang: lang, Exhibitions: make([]data.Exhibition, len(all))}
+	for i, e := range all {
+		page.Exhibitions[i] = i18n.Localize(e, lang)
+	}
+	renderer.Render(w, http.StatusOK, "index", page)
+}
+
+func handleExhibition(w http.ResponseWriter, r *http.Request) {
+	exhibition, err := data.GetBySlug(r.PathValue("slug"))
+	if err != nil {
+		renderer.Render(w, http.StatusNotFound, "notfound", nil)
+		return
+	}
+	if views != nil {
+		views.View(r, exhibition.ID, stats.Page)
+	}
+	lang := pageLanguage(w, r, i18n.Languages([]data.Exhibition{exhibition}))
+	renderer.Render(w, http.StatusOK, "exhibition", exhibitionPage{Lang: lang, Exhibition: i18n.Localize(exhibition, lang)})
+}
@@ -0,0 +1,173 @@
+// Renders /api/openapi.json and lets readers try the operations from the
+// page. Everything is built with DOM calls, so nothing from the document
+// is parsed as HTML.
+
+function element(tag, attributes = {}, ...children) {
+    const node = document.createElement(tag);
+    for (const [name, value] of Object.entries(attributes)) {
+        node.setAttribute(name, value);
+    }
+    node.append(...children.filter(child => child !== undefined && child !== null));
+    return node;
+}
+
+// credential is kept for the session, so it doesn't outlive the tab.
+const credential = document.getElementById("credential");
+credential.value = sessionStorage.getItem("museum-credential") || "";
+credential.addEventListener("change", () => sessionStorage.setItem("museum-credential", credential.value));
+
+function schemaLink(schema) {
+    if (schema && schema.$ref) {
+        const name = schema.$ref.split("/").pop();
+        return element("a", {href: "#schema-" + name}, name);
+    }
+    if (schema && schema.type === "array") {
+        return element("span", {}, "array of ", schemaLink(schema.items));
+    }
+    return element("code", {}, JSON.stringify(schema || {}));
+}
+
+function tryForm(method, path, operation) {
+    const form = element("form");
+    const parameters = operation.parameters || [];
+    for (const p of parameters) {
+        form.append(element("label", {},
+            `${p.name} (${p.in}${p.required ? ", required" : ""}) `,
+            element("input", {type: "text", name: p.name, "data-in": p.in})));
+    }
+    const body = operation.requestBody && operation.requestBody.content;
+    if (body && body["application/json"]) {
+        form.append(element("label", {}, "JSON body", element("textarea", {name: "body"})));
+    } else if (body && body["multipart/form-data"]) {
+        form.append(element("label", {}, "image ", element("input", {type: "file", name: "image"})));
+    }
+    const output = element("pre", {hidden: ""});
+    form.append(element("button", {type: "submit"}, "Send"), output);
+
+    form.addEventListener("submit", async event => {
+        event.preventDefault();
+        let url = path;
+        const query = new URLSearchParams();
+        for (const input of form.querySelectorAll("input[data-in]")) {
+            if (input.dataset.in === "path") {
+                url = url.replace(`{${input.name}}`, encodeURIComponent(input.value));
+            } else if (input.value !== "") {
+                query.set(input.name, input.value);
+            }
+        }
+        if ([...query].length > 0) {
+            url += "?" + query;
+        }
+        const options = {method: method.toUpperCase(), headers: {}};
+        if (credential.value) {
+            options.headers["Authorization"] = "Bearer " + credential.value;
+        }
+        if (form.elements.body) {
+            options.headers["Content-Type"] = "application/json";
+            options.body = form.elements.body.value;
+        } else if (form.elements.image) {
+            options.body = new FormData();
+            options.body.append("image", form.elements.image.files[0]);
+        }
+        output.hidden = false;
+        output.textContent = "Sending…";
+        try {
+            const response = await fetch(url, options);
+            const text = await response.text();
+            let shown = text;
+            try {
+                shown = JSON.stringify(JSON.parse(text), null, 2);
+            } catch {
+                // Not JSON, shown as it is
+            }
+            output.textContent = `${response.status} ${response.statusText}\n\n${shown}`;
+        } catch (error) {
+            output.textContent = String(error);
+        }
+    });
+    return form;
+}
+
+function renderOperation(method, path, operation) {
+    const summary = element("summary", {},
+        element("span", {class: "method " + method}, method),
+        element("span", {class: "path"}, path), " ",
+        operation.summary,
+        operation["x-role"] ? element("span", {class: "role"}, operation["x-role"]) : null,
+        operation.deprecated ? element("span", {class: "deprecated"}, "deprecated") : null);
+
+    const content = element("div");
+    if (operation.description) {
+        content.append(element("p", {}, operation.description));
+    }
+    const parameters = operation.parameters || [];
+    if (parameters.length > 0) {
+        const list = element("ul");
+        for (const p of parameters) {
+            list.append(element("li", {},
+                element("code", {}, p.name), ` in ${p.in}: `, schemaLink(p.schema),
+                p.description ? " — " + p.description : ""));
+        }
+        content.append(element("h4", {}, "Parameters"), list);
+    }
+    if (operation.requestBody) {
+        const list = element("ul");
+        for (const [media, body] of Object.entries(operation.requestBody.content)) {
+            list.append(element("li", {}, element("code", {}, media), " ", schemaLink(body.schema)));
+        }
+        content.append(element("h4", {}, "Body"), list);
+    }
+    const responses = element("ul");
+    for (const [status, response] of Object.entries(operation.responses)) {
+        const item = element("li", {}, element("strong", {}, status), " " + response.description);
+        for (const [media, body] of Object.entries(response.content || {})) {
+            item.append(" ", element("code", {}, media), body.schema ? " " : "", body.schema ? schemaLink(body.schema) : null);
+        }
+        responses.append(item);
+    }
+    content.append(element("h4", {}, "Responses"), responses);
+    if (!path.endsWith("/events")) {
+        content.append(element("h4", {}, "Try it"), tryForm(method, path, operation));
+    }
+    return element("details", {class: "operation"}, summary, content);
+}
+
+async function load() {
+    const main = document.getElementById("operations");
+    let doc;
+    try {
+        const response = await fetch("/api/openapi.json");
+        doc = await response.json();
+    } catch (error) {
+        main.textContent = "The API document couldn't be loaded: " + error;
+        return;
+    }
+    document.getElementById("description").textContent = doc.info.description || "";
+
+    const byTag = new Map(doc.tags.map(tag => [tag.name, []]));
+    for (const [path, methods] of Object.entries(doc.paths).sort(([a], [b]) => a.localeCompare(b))) {
+        for (const [method, operation] of Object.entries(methods)) {
+            byTag.get(operation.tags[0]).push(renderOperation(method, path, operation));
+        }
+    }
+    main.replaceChildren();
+    const nav = document.getElementById("tags");
+    for (const [tag, operations] of byTag) {
+        nav.append(element("a", {href: "#tag-" + tag}, tag));
+        main.append(element("h2", {id: "tag-" + tag}, tag), ...operations);
+    }
+
+    const schemas = document.getElementById("schemas");
+    for (const [name, schema] of Object.entries(doc.components.schemas).sort(([a], [b]) => a.localeCompare(b))) {
+        const rows = element("ul");
+        const required = schema.required || [];
+        for (const [property, value] of Object.entries(schema.properties || {})) {
+            rows.append(element("li", {},
+                element("code", {}, property), required.includes(property) ? " (always set): " : ": ",
+                schemaLink(value)));
+        }
+        schemas.append(element("h3", {id: "schema-" + name}, name), rows);
+    }
+}
+
+window.addEventListener("DOMContentLoaded", load);
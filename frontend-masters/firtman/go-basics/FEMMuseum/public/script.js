@@ -8,19 +8,26 @@ const obs = new PerformanceObserver(list => {
  
 
 
+// exhibitionArticle builds the article of an exhibition. The text comes from
+// the store, so it's only ever set as text, never parsed as markup.
+function exhibitionArticle(exhibition) {
+    const article = document.createElement("article");
+    const title = document.createElement("h2");
+    title.textContent = exhibition.title;
+    const description = document.createElement("p");
+    description.textContent = exhibition.description;
+    const img = document.createElement("img");
+    img.src = "gallery/" + encodeURI(exhibition.image);
+    img.alt = exhibition.title;
+    img.loading = "lazy";
+    article.append(title, description, img);
+    return article;
+}
+
 async function load() {
     const response = await fetch("gallery/data.json");
     const data = await response.json();
-    data.forEach(exhibition => {
-        const article = `
-            <article>
-                <h2>${exhibition.title}</h2>
-                <p>${exhibition.description}</p>
-                <img src="gallery/${exhibition.image}" loading="lazy">
-            </article>
-        `;
-        document.querySelector("main").innerHTML += article;
-    });
+    document.querySelector("main").append(...data.map(exhibitionArticle));
 }
 
 window.addEventListener("DOMContentLoaded", load);
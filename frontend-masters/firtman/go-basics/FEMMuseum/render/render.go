@@ -0,0 +1,102 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Renderer renders the pages in pages/*.tmpl, each one inside the base
+// layout from layouts/ with every partial from partials/ available.
+// Templates are parsed once and cached, unless Dev is set, in which case
+// they're parsed again on every render so edits show up without a restart.
+type Renderer struct {
+	fsys  fs.FS
+	dev   bool
+	funcs template.FuncMap
+	pages map[string]*template.Template
+}
+
+// New parses the templates in fsys, which can call funcs. In dev mode
+// parse errors are reported on render instead, so a broken template can be
+// fixed while running.
+func New(fsys fs.FS, dev bool, funcs template.FuncMap) (*Renderer, error) {
+	r := &Renderer{fsys: fsys, dev: dev, funcs: funcs}
+	if dev {
+		return r, nil
+	}
+	pages, err := r.parse()
+	if err != nil {
+		return nil, err
+	}
+	r.pages = pages
+	return r, nil
+}
+
+func (r *Renderer) parse() (map[string]*template.Template, error) {
+	shared, err := template.New("").Funcs(r.funcs).ParseFS(r.fsys, "layouts/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if partials, _ := fs.Glob(r.fsys, "partials/*.tmpl"); len(partials) > 0 {
+		if _, err := shared.ParseFS(r.fsys, partials...); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := fs.Glob(r.fsys, "pages/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		// Each page gets its own copy so their "content" blocks don't clash.
+		page, err := shared.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := page.ParseFS(r.fsys, file); err != nil {
+			return nil, err
+		}
+		pages[strings.TrimSuffix(path.Base(file), ".tmpl")] = page
+	}
+	return pages, nil
+}
+
+func (r *Renderer) page(name string) (*template.Template, error) {
+	pages := r.pages
+	if r.dev {
+		var err error
+		if pages, err = r.parse(); err != nil {
+			return nil, err
+		}
+	}
+	page, ok := pages[name]
+	if !ok {
+		return nil, fmt.Errorf("no page named %q", name)
+	}
+	return page, nil
+}
+
+// Render executes page with data into a buffer and only writes it once it
+// rendered completely, so a failing template never sends half a page.
+func (r *Renderer) Render(w http.ResponseWriter, status int, name string, data any) {
+	page, err := r.page(name)
+	var buf bytes.Buffer
+	if err == nil {
+		err = page.ExecuteTemplate(&buf, "base", data)
+	}
+	if err != nil {
+		log.Printf("Couldn't render page %s: %v", name, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
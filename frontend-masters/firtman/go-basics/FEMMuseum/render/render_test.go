@@ -0,0 +1,80 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func templates() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.tmpl":     {Data: []byte(`{{ define "base" }}<html><title>{{ template "title" . }}</title>{{ template "content" . }}</html>{{ end }}`)},
+		"partials/card.tmpl":    {Data: []byte(`{{ define "card" }}<p title="{{ .Title }}">{{ .Description }}</p>{{ end }}`)},
+		"pages/exhibition.tmpl": {Data: []byte(`{{ define "title" }}{{ .Title }}{{ end }}{{ define "content" }}{{ template "card" . }}{{ end }}`)},
+		"pages/broken.tmpl":     {Data: []byte(`{{ define "title" }}Broken{{ end }}{{ define "content" }}before{{ .Missing.Field }}{{ end }}`)},
+	}
+}
+
+type exhibition struct {
+	Title, Description string
+}
+
+func TestRenderEscapes(t *testing.T) {
+	r, err := New(templates(), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.Render(w, http.StatusOK, "exhibition", exhibition{
+		Title:       `<script>alert("title")</script>`,
+		Description: `<script>alert("description")</script>`,
+	})
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if strings.Contains(body, "<script>") {
+		t.Errorf("got %s, want the scripts escaped", body)
+	}
+	if !strings.Contains(body, "&lt;script&gt;alert(&#34;description&#34;)&lt;/script&gt;") {
+		t.Errorf("got %s, want the description as text", body)
+	}
+}
+
+func TestRenderFailsWithoutHalfAPage(t *testing.T) {
+	for _, dev := range []bool{false, true} {
+		r, err := New(templates(), dev, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"missing", "broken"} {
+			w := httptest.NewRecorder()
+			r.Render(w, http.StatusOK, name, exhibition{})
+			if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "<html>") {
+				t.Errorf("dev %v, page %s: got %d %q, want a 500 and no page", dev, name, w.Code, w.Body)
+			}
+		}
+	}
+}
+
+func TestMissingLayout(t *testing.T) {
+	fsys := templates()
+	delete(fsys, "layouts/base.tmpl")
+	if _, err := New(fsys, false, nil); err == nil {
+		t.Error("got a renderer without layouts")
+	}
+
+	// In dev mode it's reported on render.
+	r, err := New(fsys, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.Render(w, http.StatusOK, "exhibition", exhibition{Title: "Amber"})
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "Amber") {
+		t.Errorf("got %d %q, want a 500 and no page", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const snippetLength = 160 // bytes of context around the first match
+
+// highlight HTML-escapes text and wraps the words whose term is in matched
+// in <mark> tags.
+func highlight(text string, matched map[string]bool) string {
+	var b strings.Builder
+	last := 0
+	for _, t := range tokenize(text) {
+		if !matched[t.term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.start:t.end]))
+		b.WriteString("</mark>")
+		last = t.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// snippet cuts a window of text around its first match and highlights it.
+func snippet(text string, matched map[string]bool) string {
+	if len(text) <= snippetLength {
+		return highlight(text, matched)
+	}
+	first := 0
+	for _, t := range tokenize(text) {
+		if matched[t.term] {
+			first = t.start
+			break
+		}
+	}
+	start := max(0, first-snippetLength/3)
+	end := min(len(text), start+snippetLength)
+	// Don't cut words in half.
+	if start > 0 {
+		if space := strings.IndexByte(text[start:first], ' '); space >= 0 {
+			start += space + 1
+		}
+	}
+	if end < len(text) {
+		if space := strings.LastIndexByte(text[first:end], ' '); space > 0 {
+			end = first + space
+		}
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	out := highlight(text[start:end], matched)
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(text) {
+		out += "…"
+	}
+	return out
+}
@@ -0,0 +1,256 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// BM25 parameters; these are the usual defaults.
+const (
+	k1 = 1.2
+	b  = 0.75
+
+	titleWeight = 2.0 // a word in the title counts as much as two in the description
+
+	maxPrefixExpansions = 50
+	prefixPenalty       = 0.8 // words only matching as a prefix rank below exact ones
+)
+
+type document struct {
+	id          string
+	title       string
+	description string
+	length      float64
+	terms       map[string]float64 // term frequency, weighted by field
+	words       []string           // distinct surface words, for prefix lookups
+}
+
+// Index is an in-memory inverted index over exhibition titles and
+// descriptions, ranked with BM25. It is safe for concurrent use.
+type Index struct {
+	mu          sync.RWMutex
+	docs        map[string]*document
+	postings    map[string]map[string]float64 // term -> document ID -> frequency
+	words       map[string]int                // surface word -> number of documents using it
+	sortedWords []string                      // sorted keys of words, rebuilt lazily
+	totalLength float64
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]float64),
+		words:    make(map[string]int),
+	}
+}
+
+// Build indexes every exhibition in list.
+func (idx *Index) Build(list []data.Exhibition) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, e := range list {
+		idx.put(e)
+	}
+}
+
+// Apply keeps the index in sync with the store; pass it to data.Subscribe.
+func (idx *Index) Apply(c data.Change) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	switch c.Type {
+	case data.Created, data.Updated:
+		idx.put(c.Exhibition)
+	case data.Deleted:
+		idx.remove(c.Exhibition.ID)
+	}
+}
+
+func (idx *Index) put(e data.Exhibition) {
+	idx.remove(e.ID)
+	doc := &document{
+		id:          e.ID,
+		title:       e.Title,
+		description: e.Description,
+		terms:       make(map[string]float64),
+	}
+	seen := make(map[string]bool)
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{{e.Title, titleWeight}, {e.Description, 1}} {
+		for _, t := range tokenize(field.text) {
+			doc.terms[t.term] += field.weight
+			doc.length += field.weight
+			if !seen[t.word] {
+				seen[t.word] = true
+				doc.words = append(doc.words, t.word)
+			}
+		}
+	}
+
+	for term, tf := range doc.terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]float64)
+		}
+		idx.postings[term][doc.id] = tf
+	}
+	for _, word := range doc.words {
+		if idx.words[word] == 0 {
+			idx.sortedWords = nil
+		}
+		idx.words[word]++
+	}
+	idx.docs[doc.id] = doc
+	idx.totalLength += doc.length
+}
+
+func (idx *Index) remove(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	for _, word := range doc.words {
+		idx.words[word]--
+		if idx.words[word] == 0 {
+			delete(idx.words, word)
+			idx.sortedWords = nil
+		}
+	}
+	idx.totalLength -= doc.length
+	delete(idx.docs, id)
+}
+
+type Result struct {
+	ID         string            `json:"id"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Search ranks the exhibitions matching q. The last word of q also matches
+// as a prefix, so partial input like "chame" already finds "Chameleon".
+func (idx *Index) Search(q string, limit int) []Result {
+	tokens := tokenize(q)
+	if len(tokens) == 0 {
+		return nil
+	}
+	// sortedWords may need rebuilding, which writes, so take the write lock
+	// when it's missing. A change can drop it again before the read lock is
+	// back, hence the loop.
+	idx.mu.RLock()
+	for idx.sortedWords == nil && len(idx.words) > 0 {
+		idx.mu.RUnlock()
+		idx.mu.Lock()
+		idx.sortWords()
+		idx.mu.Unlock()
+		idx.mu.RLock()
+	}
+	defer idx.mu.RUnlock()
+
+	scores := make(map[string]float64)
+	matched := make(map[string]bool) // terms to highlight
+	for i, t := range tokens {
+		terms := map[string]float64{t.term: 1}
+		if i == len(tokens)-1 {
+			for _, term := range idx.prefixTerms(t.word) {
+				if _, ok := terms[term]; !ok {
+					terms[term] = prefixPenalty
+				}
+			}
+		}
+		// Each query word adds the score of its best matching term, so a
+		// prefix expanding to many terms doesn't outweigh the other words.
+		best := make(map[string]float64)
+		for term, weight := range terms {
+			postings := idx.postings[term]
+			if len(postings) == 0 {
+				continue
+			}
+			matched[term] = true
+			idf := idx.idf(len(postings))
+			for id, tf := range postings {
+				score := weight * idf * idx.saturate(tf, idx.docs[id].length)
+				best[id] = max(best[id], score)
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{ID: id, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	for i := range results {
+		doc := idx.docs[results[i].ID]
+		results[i].Highlights = map[string]string{
+			"Title":       highlight(doc.title, matched),
+			"Description": snippet(doc.description, matched),
+		}
+	}
+	return results
+}
+
+func (idx *Index) idf(df int) float64 {
+	n := float64(len(idx.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+func (idx *Index) saturate(tf, length float64) float64 {
+	avg := idx.totalLength / float64(len(idx.docs))
+	return tf * (k1 + 1) / (tf + k1*(1-b+b*length/avg))
+}
+
+// sortWords rebuilds sortedWords. Callers hold the write lock.
+func (idx *Index) sortWords() {
+	if idx.sortedWords != nil {
+		return
+	}
+	words := make([]string, 0, len(idx.words))
+	for word := range idx.words {
+		words = append(words, word)
+	}
+	slices.Sort(words)
+	idx.sortedWords = words
+}
+
+// prefixTerms returns the terms of the indexed words starting with prefix.
+func (idx *Index) prefixTerms(prefix string) []string {
+	var terms []string
+	i, _ := slices.BinarySearch(idx.sortedWords, prefix)
+	for ; i < len(idx.sortedWords) && len(terms) < maxPrefixExpansions; i++ {
+		word := idx.sortedWords[i]
+		if !strings.HasPrefix(word, prefix) {
+			break
+		}
+		terms = append(terms, Stem(word))
+	}
+	return terms
+}
+
+// Len is the number of indexed exhibitions.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":     "caress",
+		"ponies":       "poni",
+		"agreed":       "agre",
+		"hopping":      "hop",
+		"filing":       "file",
+		"happy":        "happi",
+		"relational":   "relat",
+		"adaptations":  "adapt",
+		"adjustment":   "adjust",
+		"chameleons":   "chameleon",
+		"controllable": "control",
+		"sculptures":   "sculptur",
+	}
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestIndexFollowsChanges(t *testing.T) {
+	idx := NewIndex()
+	idx.Build([]data.Exhibition{
+		{ID: "1", Title: "Sea Monsters", Description: "Myths of the deep ocean"},
+		{ID: "2", Title: "Ancient Greece", Description: "Sculptures and a sea voyage"},
+	})
+
+	results := idx.Search("sea", 10)
+	if len(results) != 2 || results[0].ID != "1" {
+		t.Fatalf("got %+v, want the title match ranked first", results)
+	}
+	if !strings.Contains(results[0].Highlights["Title"], "<mark>Sea</mark>") {
+		t.Errorf("got title highlight %q", results[0].Highlights["Title"])
+	}
+
+	idx.Apply(data.Change{Type: data.Updated, Exhibition: data.Exhibition{ID: "2", Title: "Ancient Greece", Description: "Sculptures"}})
+	idx.Apply(data.Change{Type: data.Deleted, Exhibition: data.Exhibition{ID: "1"}})
+	if results := idx.Search("sea", 10); len(results) != 0 {
+		t.Errorf("got %+v after removing every sea, want nothing", results)
+	}
+	if results := idx.Search("sculp", 10); len(results) != 1 || results[0].ID != "2" {
+		t.Errorf("got %+v for a prefix, want exhibition 2", results)
+	}
+}
+
+// TestPrefixSearchDuringChanges searches by prefix while other exhibitions
+// bring in new words, which drops the sorted words prefixes are looked up in.
+func TestPrefixSearchDuringChanges(t *testing.T) {
+	idx := NewIndex()
+	idx.Build([]data.Exhibition{{ID: "1", Title: "Amber", Description: "Fossil resin"}})
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			idx.Apply(data.Change{Type: data.Updated, Exhibition: data.Exhibition{ID: "2", Title: "word" + strconv.Itoa(i)}})
+		}
+	}()
+	for range 5000 {
+		if results := idx.Search("amb", 10); len(results) != 1 {
+			t.Errorf("got %+v, want Amber", results)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestHighlightEscapesHTML(t *testing.T) {
+	got := highlight("<b>Sea</b> monsters", map[string]bool{"sea": true})
+	want := "&lt;b&gt;<mark>Sea</mark>&lt;/b&gt; monsters"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+package search
+
+import "strings"
+
+// Stem reduces an English word to its stem with the Porter algorithm, so
+// "adaptations", "adapted" and "adapting" all become "adapt". Words that
+// aren't plain lowercase ASCII letters are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	s := stemmer{b: []byte(word)}
+	s.step1a()
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.b)
+}
+
+type stemmer struct {
+	b []byte
+}
+
+// consonant reports whether b[i] is a consonant. y is a consonant when it
+// starts the word or follows a vowel.
+func (s *stemmer) consonant(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.consonant(i-1)
+	}
+	return true
+}
+
+// measure counts the VC sequences in b[:end], the m of [C](VC)^m[V].
+func (s *stemmer) measure(end int) int {
+	m, i := 0, 0
+	for i < end && s.consonant(i) {
+		i++
+	}
+	for i < end {
+		for i < end && !s.consonant(i) {
+			i++
+		}
+		if i >= end {
+			break
+		}
+		for i < end && s.consonant(i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+func (s *stemmer) hasVowel(end int) bool {
+	for i := 0; i < end; i++ {
+		if !s.consonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant reports whether b[:end] ends with two equal consonants.
+func (s *stemmer) doubleConsonant(end int) bool {
+	return end >= 2 && s.b[end-1] == s.b[end-2] && s.consonant(end-1)
+}
+
+// cvc reports whether b[:end] ends consonant-vowel-consonant where the last
+// consonant isn't w, x or y, like "hop" but not "snow".
+func (s *stemmer) cvc(end int) bool {
+	if end < 3 || !s.consonant(end-1) || s.consonant(end-2) || !s.consonant(end-3) {
+		return false
+	}
+	switch s.b[end-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (s *stemmer) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(s.b), suffix)
+}
+
+// stemEnd is where the stem ends once suffix is removed.
+func (s *stemmer) stemEnd(suffix string) int {
+	return len(s.b) - len(suffix)
+}
+
+func (s *stemmer) replace(suffix, with string) {
+	s.b = append(s.b[:s.stemEnd(suffix)], with...)
+}
+
+// replaceIf swaps the first matching suffix of rules for its replacement
+// when the remaining stem has a measure above min. It reports whether any
+// suffix matched, even if the measure check failed.
+func (s *stemmer) replaceIf(min int, rules [][2]string) bool {
+	for _, rule := range rules {
+		if s.hasSuffix(rule[0]) {
+			if s.measure(s.stemEnd(rule[0])) > min {
+				s.replace(rule[0], rule[1])
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stemmer) step1a() {
+	switch {
+	case s.hasSuffix("sses"):
+		s.replace("sses", "ss")
+	case s.hasSuffix("ies"):
+		s.replace("ies", "i")
+	case s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		s.replace("s", "")
+	}
+}
+
+func (s *stemmer) step1b() {
+	if s.hasSuffix("eed") {
+		if s.measure(s.stemEnd("eed")) > 0 {
+			s.replace("eed", "ee")
+		}
+		return
+	}
+	removed := false
+	for _, suffix := range []string{"ed", "ing"} {
+		if s.hasSuffix(suffix) && s.hasVowel(s.stemEnd(suffix)) {
+			s.replace(suffix, "")
+			removed = true
+			break
+		}
+	}
+	if !removed {
+		return
+	}
+	end := len(s.b)
+	switch {
+	case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+		s.b = append(s.b, 'e')
+	case s.doubleConsonant(end):
+		switch s.b[end-1] {
+		case 'l', 's', 'z':
+		default:
+			s.b = s.b[:end-1]
+		}
+	case s.measure(end) == 1 && s.cvc(end):
+		s.b = append(s.b, 'e')
+	}
+}
+
+func (s *stemmer) step1c() {
+	if s.hasSuffix("y") && s.hasVowel(s.stemEnd("y")) {
+		s.replace("y", "i")
+	}
+}
+
+var step2Rules = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+func (s *stemmer) step2() {
+	s.replaceIf(0, step2Rules)
+}
+
+var step3Rules = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+func (s *stemmer) step3() {
+	s.replaceIf(0, step3Rules)
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func (s *stemmer) step4() {
+	// Longest suffix first, so "ement" wins over "ment" and "ent".
+	best := ""
+	for _, suffix := range step4Suffixes {
+		if len(suffix) > len(best) && s.hasSuffix(suffix) {
+			best = suffix
+		}
+	}
+	if best == "" {
+		return
+	}
+	end := s.stemEnd(best)
+	if s.measure(end) <= 1 {
+		return
+	}
+	if best == "ion" && (end == 0 || (s.b[end-1] != 's' && s.b[end-1] != 't')) {
+		return
+	}
+	s.b = s.b[:end]
+}
+
+func (s *stemmer) step5() {
+	if s.hasSuffix("e") {
+		end := s.stemEnd("e")
+		m := s.measure(end)
+		if m > 1 || (m == 1 && !s.cvc(end)) {
+			s.b = s.b[:end]
+		}
+	}
+	end := len(s.b)
+	if s.b[end-1] == 'l' && s.doubleConsonant(end) && s.measure(end) > 1 {
+		s.b = s.b[:end-1]
+	}
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a word of a text along with where it sits, so matches can be
+// highlighted in the original text.
+type token struct {
+	word  string // lowercased
+	term  string // stemmed form used in the index
+	start int    // byte offsets in the original text
+	end   int
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "has": true, "have": true,
+	"how": true, "in": true, "is": true, "it": true, "its": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "their": true,
+	"this": true, "to": true, "was": true, "were": true, "what": true,
+	"with": true,
+}
+
+// tokenize splits text into lowercase words and drops stop words.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := strings.ToLower(text[start:end])
+		if !stopWords[word] {
+			tokens = append(tokens, token{word: word, term: Stem(word), start: start, end: end})
+		}
+		start = -1
+	}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if r == '\'' && start >= 0 {
+			// Drop possessives and contractions: "Aristotle's" is "aristotle"
+			flush(i)
+			for i+size < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[i+size:])
+				if !unicode.IsLetter(next) {
+					break
+				}
+				size += nextSize
+			}
+		} else {
+			flush(i)
+		}
+		i += size
+	}
+	flush(len(text))
+	return tokens
+}
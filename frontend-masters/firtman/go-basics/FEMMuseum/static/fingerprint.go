@@ -0,0 +1,96 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"frontendmasters.com/go/museum/httpcache"
+)
+
+// fingerprinted lists the extensions of the assets that get fingerprints.
+// Gallery images are left out since their names are stored in exhibitions.
+var fingerprinted = map[string]bool{".css": true, ".js": true, ".png": true, ".svg": true, ".webp": true, ".jpg": true}
+
+// Assets gives static files names that change with their content, like
+// "styles.1a2b3c4d.css", so browsers can cache them forever.
+type Assets struct {
+	hashes map[string]string // "styles.css" -> "1a2b3c4d"
+}
+
+// Fingerprint hashes the assets in fsys, skipping the gallery.
+func Fingerprint(fsys fs.FS) (*Assets, error) {
+	a := &Assets{hashes: make(map[string]string)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name == "gallery" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !fingerprinted[path.Ext(name)] {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		a.hashes[name] = hex.EncodeToString(sum[:4])
+		return nil
+	})
+	return a, err
+}
+
+// Path returns the fingerprinted URL of name, or its plain URL when it
+// has no fingerprint.
+func (a *Assets) Path(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	hash, ok := a.hashes[name]
+	if !ok {
+		return "/" + name
+	}
+	ext := path.Ext(name)
+	return "/" + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Handler serves fingerprinted URLs from next under their real name with
+// immutable caching. A stale fingerprint still gets the current file, just
+// without the long caching.
+func (a *Assets) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, hash, ok := a.split(strings.TrimPrefix(r.URL.Path, "/"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if a.hashes[name] == hash {
+			w.Header().Set("Cache-Control", httpcache.Immutable)
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + name
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// split turns "styles.1a2b3c4d.css" into "styles.css" and "1a2b3c4d".
+func (a *Assets) split(p string) (name, hash string, ok bool) {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 || len(base)-dot-1 != 8 {
+		return "", "", false
+	}
+	name = base[:dot] + ext
+	if _, known := a.hashes[name]; !known {
+		return "", "", false
+	}
+	return name, base[dot+1:], true
+}
@@ -0,0 +1,31 @@
+package static
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// Mount returns base with mounted showing through at dir, like the upload
+// directory on disk inside an embedded site.
+func Mount(base fs.FS, dir string, mounted fs.FS) fs.FS {
+	return mount{base: base, dir: dir, mounted: mounted}
+}
+
+type mount struct {
+	base    fs.FS
+	dir     string
+	mounted fs.FS
+}
+
+func (m mount) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == m.dir {
+		return m.mounted.Open(".")
+	}
+	if rest, ok := strings.CutPrefix(name, m.dir+"/"); ok {
+		return m.mounted.Open(rest)
+	}
+	return m.base.Open(name)
+}
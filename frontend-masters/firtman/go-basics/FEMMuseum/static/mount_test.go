@@ -0,0 +1,28 @@
+package static
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMount(t *testing.T) {
+	site := fstest.MapFS{
+		"gallery/fem.jpg":         {Data: []byte("built in")},
+		"gallery/uploads/old.png": {Data: []byte("hidden")},
+	}
+	uploads := fstest.MapFS{"new.png": {Data: []byte("uploaded")}}
+	fsys := Mount(site, "gallery/uploads", uploads)
+
+	for name, want := range map[string]string{"gallery/fem.jpg": "built in", "gallery/uploads/new.png": "uploaded"} {
+		if got, err := fs.ReadFile(fsys, name); err != nil || string(got) != want {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+	if _, err := fs.Stat(fsys, "gallery/uploads/old.png"); err == nil {
+		t.Error("the mounted directory doesn't hide the one underneath")
+	}
+	if _, err := fs.Sub(fsys, "gallery"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,107 @@
+package static
+
+import (
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// imageFormats are the formats an image can be swapped for, best first.
+// A client only gets one it lists in Accept; "*/*" doesn't count, since
+// it doesn't say the client can decode the format.
+var imageFormats = []struct{ ext, mimeType string }{
+	{".avif", "image/avif"},
+	{".webp", "image/webp"},
+}
+
+// negotiable lists the images that may have better formats next to them.
+var negotiable = map[string]bool{".png": true, ".jpg": true, ".jpeg": true}
+
+// precompressed lists the extensions that may have .br and .gz siblings,
+// and the encodings, best first.
+var (
+	precompressed = map[string]bool{".css": true, ".js": true, ".svg": true, ".json": true}
+	encodings     = []struct{ ext, name string }{{".br", "br"}, {".gz", "gzip"}}
+)
+
+// Negotiate serves the files of next in the best form the client accepts:
+// "photo.png" as "photo.avif" or "photo.webp" when those exist, and
+// "styles.css" from "styles.css.br" or "styles.css.gz". fsys must hold the
+// same files as next. Siblings older than the file they stand for are
+// ignored, so a forgotten one never hides an update.
+func Negotiate(fsys fs.FS, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		ext := strings.ToLower(path.Ext(name))
+		switch {
+		case negotiable[ext]:
+			w.Header().Add("Vary", "Accept")
+			base := strings.TrimSuffix(name, path.Ext(name))
+			for _, format := range imageFormats {
+				if accepts(r.Header.Get("Accept"), format.mimeType) && fresh(fsys, base+format.ext, name) {
+					serveAs(w, r, next, base+format.ext)
+					return
+				}
+			}
+		case precompressed[ext]:
+			w.Header().Add("Vary", "Accept-Encoding")
+			for _, encoding := range encodings {
+				if accepts(r.Header.Get("Accept-Encoding"), encoding.name) && fresh(fsys, name+encoding.ext, name) {
+					// The type is the one of the file, not of the archive.
+					w.Header().Set("Content-Type", mime.TypeByExtension(ext))
+					w.Header().Set("Content-Encoding", encoding.name)
+					serveAs(w, r, next, name+encoding.ext)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func serveAs(w http.ResponseWriter, r *http.Request, next http.Handler, name string) {
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/" + name
+	r2.URL.RawPath = ""
+	next.ServeHTTP(w, r2)
+}
+
+// fresh reports whether sibling exists and isn't older than name.
+func fresh(fsys fs.FS, sibling, name string) bool {
+	s, err := fs.Stat(fsys, sibling)
+	if err != nil || s.IsDir() {
+		return false
+	}
+	original, err := fs.Stat(fsys, name)
+	if err != nil {
+		return false
+	}
+	return !s.ModTime().Before(original.ModTime())
+}
+
+// accepts reports whether an Accept or Accept-Encoding header explicitly
+// lists value with a non-zero quality.
+func accepts(header, value string) bool {
+	for _, part := range strings.Split(header, ",") {
+		item, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(item), value) {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			key, q, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				quality, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+				return err == nil && quality > 0
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	old, now := time.Now().Add(-time.Hour), time.Now()
+	fsys := fstest.MapFS{
+		"gallery/photo.png":  {Data: []byte("png"), ModTime: old},
+		"gallery/photo.webp": {Data: []byte("webp"), ModTime: now},
+		"gallery/stale.png":  {Data: []byte("png"), ModTime: now},
+		"gallery/stale.webp": {Data: []byte("webp"), ModTime: old},
+		"styles.css":         {Data: []byte("css"), ModTime: old},
+		"styles.css.br":      {Data: []byte("br"), ModTime: now},
+		"styles.css.gz":      {Data: []byte("gz"), ModTime: now},
+	}
+	h := Negotiate(fsys, http.FileServer(http.FS(fsys)))
+
+	for _, c := range []struct {
+		path, header, value     string
+		body, contentType, vary string
+		encoding                string
+	}{
+		{"/gallery/photo.png", "Accept", "image/avif,image/webp,*/*", "webp", "image/webp", "Accept", ""},
+		{"/gallery/photo.png", "Accept", "*/*", "png", "image/png", "Accept", ""},
+		{"/gallery/photo.png", "Accept", "image/webp;q=0", "png", "image/png", "Accept", ""},
+		{"/gallery/stale.png", "Accept", "image/webp", "png", "image/png", "Accept", ""},
+		{"/styles.css", "Accept-Encoding", "gzip, deflate, br", "br", "text/css; charset=utf-8", "Accept-Encoding", "br"},
+		{"/styles.css", "Accept-Encoding", "gzip", "gz", "text/css; charset=utf-8", "Accept-Encoding", "gzip"},
+		{"/styles.css", "Accept-Encoding", "", "css", "text/css; charset=utf-8", "Accept-Encoding", ""},
+	} {
+		r := httptest.NewRequest("GET", c.path, nil)
+		r.Header.Set(c.header, c.value)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		got := w.Result().Header
+		if w.Body.String() != c.body || got.Get("Content-Type") != c.contentType || got.Get("Vary") != c.vary || got.Get("Content-Encoding") != c.encoding {
+			t.Errorf("%s with %s %q: got %q as %q, Vary %q, encoding %q", c.path, c.header, c.value,
+				w.Body.String(), got.Get("Content-Type"), got.Get("Vary"), got.Get("Content-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Sketch is a HyperLogLog: it estimates how many distinct values were added
+// in 2^Precision bytes, with a standard error of about 1.04/sqrt(2^Precision).
+// Sketches of the same precision merge into the sketch of their union.
+type Sketch struct {
+	Precision uint8  `json:"p"`
+	Registers []byte `json:"r"`
+}
+
+func NewSketch(precision uint8) *Sketch {
+	return &Sketch{Precision: precision, Registers: make([]byte, 1<<precision)}
+}
+
+// Add counts a value by its 64 bit hash, which must be uniformly spread.
+func (s *Sketch) Add(hash uint64) {
+	index := hash >> (64 - s.Precision)
+	// The sentinel bit bounds the count when the remaining bits are zero.
+	rank := byte(bits.LeadingZeros64(hash<<s.Precision|1<<(s.Precision-1)) + 1)
+	if rank > s.Registers[index] {
+		s.Registers[index] = rank
+	}
+}
+
+// Merge adds the values counted by other, which must have the same precision.
+func (s *Sketch) Merge(other *Sketch) {
+	for i, rank := range other.Registers {
+		if rank > s.Registers[i] {
+			s.Registers[i] = rank
+		}
+	}
+}
+
+// Estimate returns the estimated number of distinct values added.
+func (s *Sketch) Estimate() uint64 {
+	m := float64(len(s.Registers))
+	sum := 0.0
+	zeros := 0
+	for _, rank := range s.Registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	estimate := alpha(m) * m * m / sum
+	// Small cardinalities are counted far better from the empty registers.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+func alpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/m)
+}
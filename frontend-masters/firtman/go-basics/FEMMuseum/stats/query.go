@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Granularity is the size of the buckets a Query reads.
+type Granularity string
+
+const (
+	Hourly Granularity = "hour"
+	Daily  Granularity = "day"
+)
+
+// Query asks for the exhibitions viewed the most in the buckets starting
+// in [From, To).
+type Query struct {
+	Granularity Granularity
+	From, To    time.Time
+	Top         int // every exhibition viewed when zero
+}
+
+// Bucket is the views of an exhibition in one hour or day.
+type Bucket struct {
+	Start    time.Time `json:"start"`
+	Views    int64     `json:"views"`
+	Visitors uint64    `json:"visitors"`
+}
+
+// Ranked is an exhibition's views over a query's range. Visitors is an
+// estimate of the distinct visitors over the whole range, not a sum.
+type Ranked struct {
+	ID        string   `json:"id"`
+	Views     int64    `json:"views"`
+	PageViews int64    `json:"pageViews"`
+	APIViews  int64    `json:"apiViews"`
+	Visitors  uint64   `json:"visitors"`
+	Buckets   []Bucket `json:"buckets"` // only the ones with views, oldest first
+}
+
+// Popular ranks the exhibitions by views, then by visitors.
+func (r *Recorder) Popular(q Query) []Ranked {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	buckets, precision := r.daily, uint8(dailyPrecision)
+	if q.Granularity == Hourly {
+		buckets, precision = r.hourly, hourlyPrecision
+	}
+
+	byID := make(map[string]*Ranked)
+	visitors := make(map[string]*Sketch)
+	for key, counts := range buckets {
+		if key.Start.Before(q.From) || !key.Start.Before(q.To) {
+			continue
+		}
+		ranked, ok := byID[key.Exhibition]
+		if !ok {
+			ranked = &Ranked{ID: key.Exhibition}
+			byID[key.Exhibition] = ranked
+			visitors[key.Exhibition] = NewSketch(precision)
+		}
+		ranked.PageViews += counts.Page
+		ranked.APIViews += counts.API
+		ranked.Buckets = append(ranked.Buckets, Bucket{
+			Start:    key.Start,
+			Views:    counts.Page + counts.API,
+			Visitors: counts.Visitors.Estimate(),
+		})
+		visitors[key.Exhibition].Merge(counts.Visitors)
+	}
+
+	list := make([]Ranked, 0, len(byID))
+	for id, ranked := range byID {
+		ranked.Views = ranked.PageViews + ranked.APIViews
+		ranked.Visitors = visitors[id].Estimate()
+		slices.SortFunc(ranked.Buckets, func(a, b Bucket) int { return a.Start.Compare(b.Start) })
+		list = append(list, *ranked)
+	}
+	slices.SortFunc(list, func(a, b Ranked) int {
+		return cmp.Or(cmp.Compare(b.Views, a.Views), cmp.Compare(b.Visitors, a.Visitors), cmp.Compare(a.ID, b.ID))
+	})
+	if q.Top > 0 && len(list) > q.Top {
+		list = list[:q.Top]
+	}
+	return list
+}
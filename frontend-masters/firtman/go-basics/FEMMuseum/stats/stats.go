@@ -0,0 +1,306 @@
+// Package stats counts how often exhibitions are viewed and by roughly how
+// many visitors. Visitors are only counted in HyperLogLog registers, from a
+// salted hash of their address and browser, so no address is ever stored.
+package stats
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/middleware"
+)
+
+// Kind tells where an exhibition was viewed.
+type Kind int
+
+const (
+	Page Kind = iota // its page, /exhibitions/{slug}
+	API              // GET /api/exhibitions/{id}
+)
+
+const (
+	// Hourly buckets are for the last couple of days and use small
+	// sketches; daily ones are kept longer and count visitors better.
+	hourlyPrecision = 10
+	dailyPrecision  = 12
+	HourlyRetention = 48 * time.Hour
+	DailyRetention  = 90 * 24 * time.Hour
+
+	// DefaultFlushInterval is how often counts are written to disk.
+	DefaultFlushInterval = time.Minute
+)
+
+// now is the clock views are bucketed by; tests replace it.
+var now = time.Now
+
+// Counts are the views of one exhibition in one bucket.
+type Counts struct {
+	Page     int64   `json:"page"`
+	API      int64   `json:"api"`
+	Visitors *Sketch `json:"visitors"`
+}
+
+type bucketKey struct {
+	Exhibition string    `json:"exhibition"`
+	Start      time.Time `json:"start"`
+}
+
+// Recorder aggregates views in memory and, when opened with a path, writes
+// them there periodically and on Close.
+type Recorder struct {
+	path string
+	// key salts the visitor hashes, so registers can't be matched against
+	// hashes of guessed addresses.
+	key []byte
+	// trusted are the proxies whose X-Forwarded-For tells the visitor's
+	// address.
+	trusted []netip.Prefix
+
+	mu     sync.Mutex
+	hourly map[bucketKey]*Counts
+	daily  map[bucketKey]*Counts
+	dirty  bool
+
+	flushMu sync.Mutex // keeps an older snapshot from being written last
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewRecorder returns a Recorder telling visitors apart by the address
+// X-Forwarded-For gives when the request comes from one of trusted.
+func NewRecorder(trusted []netip.Prefix) *Recorder {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &Recorder{
+		key:     key,
+		trusted: trusted,
+		hourly:  make(map[bucketKey]*Counts),
+		daily:   make(map[bucketKey]*Counts),
+	}
+}
+
+// file is how a Recorder is saved.
+type file struct {
+	Key    []byte   `json:"key"`
+	Hourly []bucket `json:"hourly"`
+	Daily  []bucket `json:"daily"`
+}
+
+type bucket struct {
+	bucketKey
+	Counts
+}
+
+// check reports a bucket whose sketch can't be added to, which would panic
+// later on.
+func (b bucket) check(precision uint8) error {
+	switch {
+	case b.Visitors == nil:
+		return fmt.Errorf("the bucket of %s at %s has no visitors", b.Exhibition, b.Start)
+	case b.Visitors.Precision != precision || len(b.Visitors.Registers) != 1<<precision:
+		return fmt.Errorf("the bucket of %s at %s has a sketch of precision %d with %d registers, want %d",
+			b.Exhibition, b.Start, b.Visitors.Precision, len(b.Visitors.Registers), precision)
+	}
+	return nil
+}
+
+// OpenRecorder loads the counts saved in path and starts writing them back
+// every interval.
+func OpenRecorder(path string, interval time.Duration, trusted []netip.Prefix) (*Recorder, error) {
+	r := NewRecorder(trusted)
+	r.path = path
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		var f file
+		if err := json.Unmarshal(content, &f); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if len(f.Key) == 0 {
+			return nil, fmt.Errorf("reading %s: the key is missing", path)
+		}
+		r.key = f.Key
+		for _, b := range f.Hourly {
+			if err := b.check(hourlyPrecision); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			r.hourly[b.bucketKey] = &b.Counts
+		}
+		for _, b := range f.Daily {
+			if err := b.check(dailyPrecision); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			r.daily[b.bucketKey] = &b.Counts
+		}
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.flushEvery(interval)
+	return r, nil
+}
+
+func (r *Recorder) flushEvery(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				log.Printf("Couldn't save the view counts: %v", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Close writes the counts one last time.
+func (r *Recorder) Close() error {
+	if r.stop == nil {
+		return nil
+	}
+	close(r.stop)
+	<-r.done
+	return r.Flush()
+}
+
+// View records that the client of req viewed an exhibition.
+func (r *Recorder) View(req *http.Request, exhibition string, kind Kind) {
+	r.Record(exhibition, kind, r.visitor(req), now())
+}
+
+// visitor hashes who sent req: the address and browser, which tell apart
+// visitors behind the same address well enough for an estimate.
+func (r *Recorder) visitor(req *http.Request) uint64 {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(middleware.ClientIP(req, r.trusted).String()))
+	mac.Write([]byte{0})
+	mac.Write([]byte(req.UserAgent()))
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// Record counts a view at t by the visitor with the given hash.
+func (r *Recorder) Record(exhibition string, kind Kind, visitor uint64, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range []struct {
+		buckets   map[bucketKey]*Counts
+		start     time.Time
+		precision uint8
+	}{
+		{r.hourly, t.UTC().Truncate(time.Hour), hourlyPrecision},
+		{r.daily, startOfDay(t), dailyPrecision},
+	} {
+		key := bucketKey{exhibition, b.start}
+		counts, ok := b.buckets[key]
+		if !ok {
+			// A new bucket is a good time to drop the expired ones, which
+			// also keeps a Recorder that's never flushed small.
+			r.prune(t)
+			counts = &Counts{Visitors: NewSketch(b.precision)}
+			b.buckets[key] = counts
+		}
+		if kind == API {
+			counts.API++
+		} else {
+			counts.Page++
+		}
+		counts.Visitors.Add(visitor)
+	}
+	r.dirty = true
+}
+
+// startOfDay is when the day of t starts in the museum's time zone.
+func startOfDay(t time.Time) time.Time {
+	t = t.In(data.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).UTC()
+}
+
+// prune drops the buckets past their retention. r.mu must be held.
+func (r *Recorder) prune(t time.Time) {
+	for key := range r.hourly {
+		if key.Start.Before(t.Add(-HourlyRetention)) {
+			delete(r.hourly, key)
+			r.dirty = true
+		}
+	}
+	for key := range r.daily {
+		if key.Start.Before(t.Add(-DailyRetention)) {
+			delete(r.daily, key)
+			r.dirty = true
+		}
+	}
+}
+
+// Flush writes the counts when they changed since the last time.
+func (r *Recorder) Flush() error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+	r.mu.Lock()
+	r.prune(now())
+	if r.path == "" || !r.dirty {
+		r.mu.Unlock()
+		return nil
+	}
+	f := file{Key: r.key}
+	for key, counts := range r.hourly {
+		f.Hourly = append(f.Hourly, bucket{key, cloneCounts(counts)})
+	}
+	for key, counts := range r.daily {
+		f.Daily = append(f.Daily, bucket{key, cloneCounts(counts)})
+	}
+	r.dirty = false
+	r.mu.Unlock()
+
+	content, err := json.Marshal(f)
+	if err == nil {
+		err = writeFile(r.path, content)
+	}
+	if err != nil {
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+	}
+	return err
+}
+
+func cloneCounts(c *Counts) Counts {
+	copied := *c
+	copied.Visitors = &Sketch{Precision: c.Visitors.Precision, Registers: append([]byte(nil), c.Visitors.Registers...)}
+	return copied
+}
+
+// writeFile replaces name atomically. It's only readable by its owner
+// since it holds the key.
+func writeFile(name string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".stats-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
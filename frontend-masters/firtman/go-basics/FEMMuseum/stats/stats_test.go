@@ -0,0 +1,132 @@
+package stats
+
+import (
+	"math/rand/v2"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSketchEstimates(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		s := NewSketch(dailyPrecision)
+		for range 3 { // duplicates don't count
+			rng := rand.New(rand.NewPCG(1, 2))
+			for range n {
+				s.Add(rng.Uint64())
+			}
+		}
+		got := float64(s.Estimate())
+		if diff := got - float64(n); diff > 0.05*float64(n)+1 || -diff > 0.05*float64(n)+1 {
+			t.Errorf("estimated %v for %d distinct values", got, n)
+		}
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	a, b := NewSketch(hourlyPrecision), NewSketch(hourlyPrecision)
+	rng := rand.New(rand.NewPCG(3, 4))
+	for i := range 2000 {
+		v := rng.Uint64()
+		if i < 1500 {
+			a.Add(v)
+		}
+		if i >= 500 {
+			b.Add(v)
+		}
+	}
+	a.Merge(b)
+	if got := a.Estimate(); got < 1800 || got > 2200 {
+		t.Fatalf("estimated %d for the union of 2000", got)
+	}
+}
+
+func TestPopular(t *testing.T) {
+	r := NewRecorder(nil)
+	at := time.Date(2024, 4, 6, 10, 30, 0, 0, time.UTC)
+	for i := range 30 {
+		r.Record("amber", Page, uint64(i%3)<<60, at)
+	}
+	for i := range 10 {
+		r.Record("basalt", API, uint64(i)<<58, at.Add(time.Hour))
+	}
+	r.Record("coral", Page, 1, at.Add(-48*time.Hour))
+
+	list := r.Popular(Query{Granularity: Daily, From: at.Add(-24 * time.Hour), To: at.Add(24 * time.Hour), Top: 2})
+	if len(list) != 2 || list[0].ID != "amber" || list[0].Views != 30 || list[0].Visitors != 3 {
+		t.Fatalf("got %+v", list)
+	}
+	if b := list[1]; b.ID != "basalt" || b.APIViews != 10 || b.PageViews != 0 || b.Visitors != 10 {
+		t.Fatalf("got %+v", b)
+	}
+
+	hourly := r.Popular(Query{Granularity: Hourly, From: at.Truncate(time.Hour), To: at.Add(2 * time.Hour)})
+	if len(hourly) != 2 || len(hourly[1].Buckets) != 1 || !hourly[1].Buckets[0].Start.Equal(at.Truncate(time.Hour).Add(time.Hour)) {
+		t.Fatalf("got %+v", hourly)
+	}
+}
+
+func TestFlushKeepsNoAddresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	r, err := OpenRecorder(path, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/api/exhibitions/1", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("User-Agent", "Firefox")
+	r.View(req, "amber", API)
+	r.View(req, "amber", Page)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "203.0.113.7") || strings.Contains(string(content), "Firefox") {
+		t.Fatalf("the address was written: %s", content)
+	}
+
+	r, err = OpenRecorder(path, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.View(req, "amber", API) // the same visitor after a restart
+	list := r.Popular(Query{Granularity: Daily, From: time.Now().Add(-48 * time.Hour), To: time.Now().Add(24 * time.Hour)})
+	if len(list) != 1 || list[0].Views != 3 || list[0].Visitors != 1 {
+		t.Fatalf("after a restart got %+v", list)
+	}
+}
+
+func TestVisitorsBehindAProxy(t *testing.T) {
+	r := NewRecorder([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	for _, client := range []string{"203.0.113.7", "203.0.113.8", "203.0.113.8"} {
+		req := httptest.NewRequest("GET", "/api/exhibitions/1", nil)
+		req.RemoteAddr = "10.0.0.1:51234"
+		req.Header.Set("X-Forwarded-For", client)
+		r.View(req, "amber", API)
+	}
+	list := r.Popular(Query{Granularity: Daily, From: time.Now().Add(-48 * time.Hour), To: time.Now().Add(24 * time.Hour)})
+	if len(list) != 1 || list[0].Views != 3 || list[0].Visitors != 2 {
+		t.Fatalf("got %+v, want the forwarded addresses told apart", list)
+	}
+}
+
+func TestOpenRecorderRefusesBadSketches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	content := `{"key": "c2VjcmV0", "hourly": [{"exhibition": "amber", "start": "2024-05-01T10:00:00Z", "page": 1, "visitors": {"p": 10, "r": "AAAA"}}]}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := OpenRecorder(path, time.Hour, nil); err == nil {
+		r.Close()
+		t.Fatal("opened a sketch of 3 registers")
+	}
+}
@@ -0,0 +1,72 @@
+package tickets
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// Slot is a window in which a ticket lets visitors in.
+type Slot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Capacity  int       `json:"capacity"`
+	Available int       `json:"available"`
+}
+
+// Slots cuts the opening hours of e on day, a date in the museum's time
+// zone, into its entry slots. A slot that wouldn't end by closing time is
+// left out. Exhibitions without timed entry have none.
+func Slots(e data.Exhibition, day string) []Slot {
+	if e.Entry == nil || e.Schedule == nil || e.Entry.SlotMinutes <= 0 {
+		return nil
+	}
+	s := e.Schedule
+	if (s.Opens != "" && day < s.Opens) || (s.Closes != "" && day > s.Closes) || slices.Contains(s.Closures, day) {
+		return nil
+	}
+	date, err := time.ParseInLocation(data.DateFormat, day, data.Location())
+	if err != nil {
+		return nil
+	}
+	weekday := data.Weekdays[date.Weekday()]
+	length := time.Duration(e.Entry.SlotMinutes) * time.Minute
+	var slots []Slot
+	for _, h := range s.Hours {
+		if !strings.EqualFold(h.Day, weekday) {
+			continue
+		}
+		open, okOpen := at(date, h.Open)
+		close, okClose := at(date, h.Close)
+		if !okOpen || !okClose {
+			continue
+		}
+		for start := open; !start.Add(length).After(close); start = start.Add(length) {
+			slots = append(slots, Slot{Start: start, End: start.Add(length), Capacity: e.Entry.Capacity})
+		}
+	}
+	slices.SortFunc(slots, func(a, b Slot) int { return a.Start.Compare(b.Start) })
+	return slots
+}
+
+// at is the wall clock time clock ("15:04") on date.
+func at(date time.Time, clock string) (time.Time, bool) {
+	t, err := time.Parse(data.TimeFormat, clock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location()), true
+}
+
+// slotAt finds the slot of e starting at start.
+func slotAt(e data.Exhibition, start time.Time) (Slot, bool) {
+	day := start.In(data.Location()).Format(data.DateFormat)
+	for _, slot := range Slots(e, day) {
+		if slot.Start.Equal(start) {
+			return slot, true
+		}
+	}
+	return Slot{}, false
+}
@@ -0,0 +1,318 @@
+// Package tickets books timed entries to exhibitions: a visitor holds
+// places in a slot for a few minutes, then confirms the hold to get a
+// ticket that's checked in at the door.
+package tickets
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+const (
+	// DefaultHoldTTL is how long held places are kept for confirmation.
+	DefaultHoldTTL = 10 * time.Minute
+	// MaxQuantity is how many places one ticket can be for.
+	MaxQuantity = 10
+	// DefaultMaxHeld is how many places one client can hold at once, so
+	// nobody can keep a whole slot from being booked.
+	DefaultMaxHeld = 2 * MaxQuantity
+)
+
+var (
+	ErrNoSlot           = errors.New("tickets: no such slot")
+	ErrSlotStarted      = errors.New("tickets: the slot already started")
+	ErrBadQuantity      = errors.New("tickets: bad quantity")
+	ErrSoldOut          = errors.New("tickets: not enough places left")
+	ErrTooManyHeld      = errors.New("tickets: too many places held")
+	ErrHoldNotFound     = errors.New("tickets: hold not found")
+	ErrHoldExpired      = errors.New("tickets: hold expired")
+	ErrTicketNotFound   = errors.New("tickets: ticket not found")
+	ErrAlreadyCheckedIn = errors.New("tickets: already checked in")
+)
+
+// now is the clock holds expire by; tests replace it.
+var now = time.Now
+
+// Hold keeps places in a slot until Expires.
+type Hold struct {
+	ID         string    `json:"id"`
+	Exhibition string    `json:"exhibition"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Quantity   int       `json:"quantity"`
+	Expires    time.Time `json:"expires"`
+	client     string
+}
+
+// Ticket is a confirmed booking. Code only uses uppercase letters, digits
+// and dashes, so it fits the compact alphanumeric mode of QR codes.
+type Ticket struct {
+	Code       string    `json:"code"`
+	Exhibition string    `json:"exhibition"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Quantity   int       `json:"quantity"`
+	Name       string    `json:"name"`
+	Booked     time.Time `json:"booked"`
+	CheckedIn  time.Time `json:"checkedIn,omitzero"`
+}
+
+type slotKey struct {
+	exhibition string
+	start      int64
+}
+
+func keyOf(exhibition string, start time.Time) slotKey {
+	return slotKey{exhibition, start.Unix()}
+}
+
+// Booker hands out places without ever selling more than a slot's
+// capacity: counting what's taken and taking places happen under one lock.
+// Tickets are written to a journal when a path is given; holds only live
+// in memory, being short-lived.
+type Booker struct {
+	HoldTTL time.Duration
+	// MaxHeld is how many places one client can hold at once; unlimited
+	// when zero.
+	MaxHeld int
+
+	mu      sync.Mutex
+	holds   map[string]Hold
+	tickets map[string]*Ticket
+	booked  map[slotKey]int // places taken by tickets
+	file    *os.File
+}
+
+func NewBooker() *Booker {
+	return &Booker{
+		HoldTTL: DefaultHoldTTL,
+		MaxHeld: DefaultMaxHeld,
+		holds:   make(map[string]Hold),
+		tickets: make(map[string]*Ticket),
+		booked:  make(map[slotKey]int),
+	}
+}
+
+// OpenBooker loads the tickets journaled in path and keeps appending to it.
+func OpenBooker(path string) (*Booker, error) {
+	b := NewBooker()
+	if err := b.load(path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	b.file = file
+	return b, nil
+}
+
+func (b *Booker) load(path string) error {
+	return data.ReadJournal(path, func(t Ticket, _ int) error {
+		if _, ok := b.tickets[t.Code]; !ok {
+			b.booked[keyOf(t.Exhibition, t.Start)] += t.Quantity
+		}
+		b.tickets[t.Code] = &t
+		return nil
+	})
+}
+
+func (b *Booker) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	return b.file.Close()
+}
+
+// save journals t and syncs it, since its code is handed out as soon as
+// this returns. b.mu must be held.
+func (b *Booker) save(t *Ticket) error {
+	if b.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if _, err := b.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return b.file.Sync()
+}
+
+// taken counts the places of a slot that are booked or held, and the
+// places client holds in any slot, dropping the expired holds on the way.
+// b.mu must be held.
+func (b *Booker) taken(key slotKey, client string, t time.Time) (taken, held int) {
+	taken = b.booked[key]
+	for id, h := range b.holds {
+		if !t.Before(h.Expires) {
+			delete(b.holds, id)
+			continue
+		}
+		if keyOf(h.Exhibition, h.Start) == key {
+			taken += h.Quantity
+		}
+		if client != "" && h.client == client {
+			held += h.Quantity
+		}
+	}
+	return taken, held
+}
+
+// Availability returns the slots of e on day with the places left.
+func (b *Booker) Availability(e data.Exhibition, day string) []Slot {
+	slots := Slots(e, day)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := now()
+	for i, slot := range slots {
+		taken, _ := b.taken(keyOf(e.ID, slot.Start), "", t)
+		slots[i].Available = max(0, slot.Capacity-taken)
+	}
+	return slots
+}
+
+// Hold keeps quantity places of the slot of e starting at start for
+// client, who may hold MaxHeld places at once. An empty client isn't
+// limited.
+func (b *Booker) Hold(client string, e data.Exhibition, start time.Time, quantity int) (Hold, error) {
+	if quantity < 1 || quantity > MaxQuantity {
+		return Hold{}, ErrBadQuantity
+	}
+	slot, ok := slotAt(e, start)
+	if !ok {
+		return Hold{}, ErrNoSlot
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := now()
+	if !t.Before(slot.Start) {
+		return Hold{}, ErrSlotStarted
+	}
+	taken, held := b.taken(keyOf(e.ID, slot.Start), client, t)
+	if client != "" && b.MaxHeld > 0 && held+quantity > b.MaxHeld {
+		return Hold{}, ErrTooManyHeld
+	}
+	if taken+quantity > slot.Capacity {
+		return Hold{}, ErrSoldOut
+	}
+	h := Hold{
+		ID:         randomHex(16),
+		Exhibition: e.ID,
+		Start:      slot.Start,
+		End:        slot.End,
+		Quantity:   quantity,
+		Expires:    t.Add(b.HoldTTL),
+		client:     client,
+	}
+	b.holds[h.ID] = h
+	return h, nil
+}
+
+// Release gives the places of a hold back.
+func (b *Booker) Release(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.holds[id]; !ok {
+		return ErrHoldNotFound
+	}
+	delete(b.holds, id)
+	return nil
+}
+
+// Confirm turns a hold into a ticket for name.
+func (b *Booker) Confirm(id, name string) (Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.holds[id]
+	if !ok {
+		return Ticket{}, ErrHoldNotFound
+	}
+	t := now()
+	if !t.Before(h.Expires) {
+		delete(b.holds, id)
+		return Ticket{}, ErrHoldExpired
+	}
+	ticket := &Ticket{
+		Code:       newCode(),
+		Exhibition: h.Exhibition,
+		Start:      h.Start,
+		End:        h.End,
+		Quantity:   h.Quantity,
+		Name:       name,
+		Booked:     t.UTC(),
+	}
+	if err := b.save(ticket); err != nil {
+		return Ticket{}, err
+	}
+	delete(b.holds, id)
+	b.tickets[ticket.Code] = ticket
+	b.booked[keyOf(h.Exhibition, h.Start)] += h.Quantity
+	return *ticket, nil
+}
+
+func (b *Booker) Ticket(code string) (Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ticket, ok := b.tickets[NormalizeCode(code)]
+	if !ok {
+		return Ticket{}, ErrTicketNotFound
+	}
+	return *ticket, nil
+}
+
+// CheckIn lets a ticket in. It only works once; after that it returns
+// the ticket with ErrAlreadyCheckedIn, to show when it was used.
+func (b *Booker) CheckIn(code string) (Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ticket, ok := b.tickets[NormalizeCode(code)]
+	if !ok {
+		return Ticket{}, ErrTicketNotFound
+	}
+	if !ticket.CheckedIn.IsZero() {
+		return *ticket, ErrAlreadyCheckedIn
+	}
+	checkedIn := *ticket
+	checkedIn.CheckedIn = now().UTC()
+	if err := b.save(&checkedIn); err != nil {
+		return Ticket{}, err
+	}
+	*ticket = checkedIn
+	return checkedIn, nil
+}
+
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newCode returns 80 random bits as "XXXX-XXXX-XXXX-XXXX".
+func newCode() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	code := codeEncoding.EncodeToString(b)
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+}
+
+// NormalizeCode accepts codes typed in lowercase or without the dashes.
+func NormalizeCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 16 {
+		return code
+	}
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+}
+
+func randomHex(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
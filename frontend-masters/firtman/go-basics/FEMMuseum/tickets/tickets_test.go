@@ -0,0 +1,225 @@
+package tickets
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// amber is bookable on saturdays from 10:00 to 12:30, by the hour.
+var amber = data.Exhibition{
+	ID:    "1",
+	Title: "Amber",
+	Schedule: &data.Schedule{
+		Hours: []data.OpeningHours{{Day: "saturday", Open: "10:00", Close: "12:30"}},
+	},
+	Entry: &data.TimedEntry{SlotMinutes: 60, Capacity: 10},
+}
+
+var (
+	ten    = time.Date(2024, 4, 6, 10, 0, 0, 0, time.UTC)
+	eleven = ten.Add(time.Hour)
+)
+
+func setClock(t *testing.T, at time.Time) *time.Time {
+	clock := at
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+	return &clock
+}
+
+func TestSlots(t *testing.T) {
+	slots := Slots(amber, "2024-04-06")
+	// 12:00-13:00 would end after closing.
+	if len(slots) != 2 || !slots[0].Start.Equal(ten) || !slots[1].End.Equal(ten.Add(2*time.Hour)) {
+		t.Fatalf("got %+v", slots)
+	}
+	if slots := Slots(amber, "2024-04-07"); len(slots) != 0 {
+		t.Fatalf("got slots on a sunday: %+v", slots)
+	}
+}
+
+func TestNoOverbookingUnderConcurrency(t *testing.T) {
+	setClock(t, ten.Add(-24*time.Hour))
+	b := NewBooker()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var held []Hold
+	soldOut := 0
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, err := b.Hold("", amber, ten, 1)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				held = append(held, h)
+			case errors.Is(err, ErrSoldOut):
+				soldOut++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(held) != 10 || soldOut != 40 {
+		t.Fatalf("%d held and %d sold out for 10 places", len(held), soldOut)
+	}
+
+	// Confirming concurrently doesn't free or take any more places.
+	for _, h := range held {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.Confirm(h.ID, "Visitor"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := b.Hold("", amber, ten, 1); !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("a full slot gave a hold: %v", err)
+	}
+	if slots := b.Availability(amber, "2024-04-06"); slots[0].Available != 0 || slots[1].Available != 10 {
+		t.Fatalf("got %+v", slots)
+	}
+}
+
+func TestHoldsExpire(t *testing.T) {
+	clock := setClock(t, ten.Add(-time.Hour))
+	b := NewBooker()
+	h, err := b.Hold("", amber, eleven, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Hold("", amber, eleven, 1); !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("got %v", err)
+	}
+	*clock = clock.Add(DefaultHoldTTL)
+	if _, err := b.Confirm(h.ID, "Late"); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("confirming an expired hold got %v", err)
+	}
+	if _, err := b.Hold("", amber, eleven, 10); err != nil {
+		t.Fatalf("the places of the expired hold weren't given back: %v", err)
+	}
+}
+
+func TestClientsHoldFewPlacesAtOnce(t *testing.T) {
+	setClock(t, ten.Add(-time.Hour))
+	b := NewBooker()
+	b.MaxHeld = 5
+	h, err := b.Hold("mallory", amber, ten, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Hold("mallory", amber, eleven, 2); !errors.Is(err, ErrTooManyHeld) {
+		t.Fatalf("holding in another slot got %v, want ErrTooManyHeld", err)
+	}
+	if _, err := b.Hold("alice", amber, ten, 2); err != nil {
+		t.Fatalf("another client got %v", err)
+	}
+	if _, err := b.Confirm(h.ID, "Mallory"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Hold("mallory", amber, eleven, 5); err != nil {
+		t.Fatalf("confirmed places still counted as held: %v", err)
+	}
+}
+
+func TestHoldChecksTheSlot(t *testing.T) {
+	setClock(t, ten.Add(30*time.Minute))
+	b := NewBooker()
+	for _, c := range []struct {
+		start    time.Time
+		quantity int
+		want     error
+	}{
+		{ten.Add(15 * time.Minute), 1, ErrNoSlot},
+		{ten, 1, ErrSlotStarted},
+		{eleven, 0, ErrBadQuantity},
+		{eleven, MaxQuantity + 1, ErrBadQuantity},
+	} {
+		if _, err := b.Hold("", amber, c.start, c.quantity); !errors.Is(err, c.want) {
+			t.Errorf("Hold(%s, %d) got %v, want %v", c.start, c.quantity, err, c.want)
+		}
+	}
+}
+
+func TestCheckInOnceAndAfterRestart(t *testing.T) {
+	setClock(t, ten.Add(-time.Hour))
+	path := filepath.Join(t.TempDir(), "tickets")
+	b, err := OpenBooker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := b.Hold("", amber, ten, 4)
+	ticket, err := b.Confirm(h.ID, "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	b, err = OpenBooker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if slots := b.Availability(amber, "2024-04-06"); slots[0].Available != 6 {
+		t.Fatalf("after a restart got %+v", slots)
+	}
+	code := ticket.Code
+	for _, c := range code {
+		if !(c >= 'A' && c <= 'Z' || c >= '2' && c <= '7' || c == '-') {
+			t.Fatalf("%q isn't QR alphanumeric", code)
+		}
+	}
+	if _, err := b.CheckIn(strings.ToLower(strings.ReplaceAll(code, "-", ""))); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := b.CheckIn(code); !errors.Is(err, ErrAlreadyCheckedIn) || again.CheckedIn.IsZero() {
+		t.Fatalf("a second check in got %+v, %v", again, err)
+	}
+	if _, err := b.CheckIn("AAAA-BBBB-CCCC-DDDD"); !errors.Is(err, ErrTicketNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestTicketsAfterATornLineSurvive(t *testing.T) {
+	setClock(t, ten.Add(-time.Hour))
+	path := filepath.Join(t.TempDir(), "tickets")
+	b, _ := OpenBooker(path)
+	h, _ := b.Hold("", amber, ten, 4)
+	b.Confirm(h.ID, "Ada")
+	b.file.WriteString(`{"code":"AAAA-`) // a crash mid-write
+	b.Close()
+
+	b, err := OpenBooker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ = b.Hold("", amber, ten, 5)
+	later, err := b.Confirm(h.ID, "Grace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	b, err = OpenBooker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if _, err := b.Ticket(later.Code); err != nil {
+		t.Errorf("the ticket confirmed after the crash is gone: %v", err)
+	}
+	if slots := b.Availability(amber, "2024-04-06"); slots[0].Available != 1 {
+		t.Errorf("got %+v, want 9 places booked", slots[0])
+	}
+}
@@ -0,0 +1,139 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// exported is the shape of public/gallery/data.json, which the static
+// frontend reads with lowercase keys.
+type exported struct {
+	ID              string `json:"id"`
+	Slug            string `json:"slug"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	Image           string `json:"image"`
+	Color           string `json:"color"`
+	CurrentlyOpened bool   `json:"currentlyOpened"`
+
+	Schedule     *data.Schedule              `json:"schedule,omitempty"`
+	Entry        *data.TimedEntry            `json:"entry,omitempty"`
+	Translations map[string]data.Translation `json:"translations,omitempty"`
+}
+
+func WriteJSON(w io.Writer, list []data.Exhibition) error {
+	out := make([]exported, len(list))
+	for i, e := range list {
+		out[i] = exported{e.ID, e.Slug, e.Title, e.Description, e.Image, e.Color, e.CurrentlyOpened, e.Schedule, e.Entry, e.Translations}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(out)
+}
+
+func WriteCSV(w io.Writer, list []data.Exhibition) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "slug", "title", "description", "image", "color", "currentlyOpened", "schedule", "entry", "translations"})
+	for _, e := range list {
+		writer.Write([]string{
+			e.ID, e.Slug, e.Title, e.Description, e.Image, e.Color, strconv.FormatBool(e.CurrentlyOpened),
+			marshalCell(e.Schedule), marshalCell(e.Entry), marshalCell(e.Translations),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// marshalCell writes v as JSON for a CSV cell, and nothing when it's empty.
+func marshalCell(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return ""
+	}
+	return string(b)
+}
+
+// scheduleCheck is how often the mirror looks for exhibitions a schedule
+// opened or closed, which no change is notified for.
+const scheduleCheck = time.Minute
+
+// Mirror keeps a JSON export of the store, like the frontend's data.json,
+// up to date. Changes only wake up a background writer, so subscribers
+// aren't held up by disk writes, and bursts of changes are written once.
+type Mirror struct {
+	path    string
+	changed chan struct{}
+	written []byte // what's in the file, only used by the writer
+}
+
+// StartMirror writes path now and again after every change it's notified
+// of, and whenever a schedule opens or closes an exhibition.
+func StartMirror(path string) (*Mirror, error) {
+	m := &Mirror{path: path, changed: make(chan struct{}, 1)}
+	if err := m.write(); err != nil {
+		return nil, err
+	}
+	go func() {
+		schedules := time.NewTicker(scheduleCheck)
+		defer schedules.Stop()
+		for {
+			select {
+			case <-m.changed:
+			case <-schedules.C:
+			}
+			if err := m.write(); err != nil {
+				log.Printf("Couldn't update %s: %v", m.path, err)
+			}
+		}
+	}()
+	return m, nil
+}
+
+// Notify schedules a rewrite; pass it to data.Subscribe.
+func (m *Mirror) Notify(data.Change) {
+	select {
+	case m.changed <- struct{}{}:
+	default: // a rewrite is already pending and will see this change
+	}
+}
+
+// write replaces the file with the current export, unless that's what it
+// already holds.
+func (m *Mirror) write() error {
+	var content bytes.Buffer
+	if err := WriteJSON(&content, data.GetAll()); err != nil {
+		return err
+	}
+	if m.written != nil && bytes.Equal(content.Bytes(), m.written) {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".data-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return err
+	}
+	m.written = content.Bytes()
+	return nil
+}
@@ -0,0 +1,203 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// How imported records are matched to stored exhibitions.
+const (
+	MatchByTitle = "title" // case-insensitively
+	MatchByID    = "id"
+)
+
+type Options struct {
+	MatchBy string
+	// Overwrite updates stored exhibitions whose fields differ from the
+	// import. Without it those records are reported as conflicts and left
+	// alone.
+	Overwrite bool
+	// DryRun reports what would happen without writing anything.
+	DryRun bool
+	// Validate checks every exhibition before it's written, when set.
+	Validate func(data.Exhibition) error
+	// Variants finds the generated copies of an image, for exhibitions
+	// whose image the import changes. Without it they have none.
+	Variants func(image string) *data.ImageVariants
+}
+
+// errSkipped stops data.Modify from writing a record that isn't imported.
+var errSkipped = errors.New("skipped")
+
+type FieldChange struct {
+	Field    string `json:"field"`
+	Current  any    `json:"current"`
+	Incoming any    `json:"incoming"`
+}
+
+// Outcome is what happened to one record of the import.
+type Outcome struct {
+	Row     int           `json:"row"` // 1-based position in the file
+	ID      string        `json:"id,omitempty"`
+	Title   string        `json:"title"`
+	Reason  string        `json:"reason,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+type Report struct {
+	DryRun    bool      `json:"dryRun"`
+	Created   []Outcome `json:"created"`
+	Updated   []Outcome `json:"updated"`
+	Unchanged []Outcome `json:"unchanged"`
+	Conflicts []Outcome `json:"conflicts"`
+	Invalid   []Outcome `json:"invalid"`
+}
+
+// Merge writes records into the store through the data package, so the
+// usual change notifications fire, and reports what it did with each.
+func Merge(ctx context.Context, records []Record, opts Options) (*Report, error) {
+	if opts.MatchBy != MatchByTitle && opts.MatchBy != MatchByID {
+		return nil, fmt.Errorf("records can be matched by %q or %q, not %q", MatchByTitle, MatchByID, opts.MatchBy)
+	}
+	report := &Report{
+		DryRun:    opts.DryRun,
+		Created:   []Outcome{},
+		Updated:   []Outcome{},
+		Unchanged: []Outcome{},
+		Conflicts: []Outcome{},
+		Invalid:   []Outcome{},
+	}
+
+	byKey := make(map[string][]data.Exhibition)
+	for _, e := range data.GetAll() {
+		byKey[matchKey(opts.MatchBy, e.ID, e.Title)] = append(byKey[matchKey(opts.MatchBy, e.ID, e.Title)], e)
+	}
+	seen := make(map[string]int) // key -> row that used it first
+
+	for i, rec := range records {
+		row := i + 1
+		outcome := Outcome{Row: row, ID: rec.id(), Title: rec.title()}
+		key := matchKey(opts.MatchBy, rec.id(), rec.title())
+		if key == "" {
+			outcome.Reason = "the record has no " + opts.MatchBy + " to match it by"
+			report.Invalid = append(report.Invalid, outcome)
+			continue
+		}
+		if first, dup := seen[key]; dup {
+			outcome.Reason = fmt.Sprintf("row %d already has this %s", first, opts.MatchBy)
+			report.Conflicts = append(report.Conflicts, outcome)
+			continue
+		}
+		seen[key] = row
+
+		matches := byKey[key]
+		switch {
+		case len(matches) > 1:
+			outcome.Reason = fmt.Sprintf("%d stored exhibitions share this %s", len(matches), opts.MatchBy)
+			report.Conflicts = append(report.Conflicts, outcome)
+
+		case len(matches) == 0 && opts.MatchBy == MatchByID:
+			outcome.Reason = "no stored exhibition has this ID"
+			report.Conflicts = append(report.Conflicts, outcome)
+
+		case len(matches) == 0:
+			e := rec.apply(data.Exhibition{}, opts.Variants)
+			if err := validate(opts, e); err != nil {
+				outcome.Reason = err.Error()
+				report.Invalid = append(report.Invalid, outcome)
+				continue
+			}
+			if !opts.DryRun {
+				created, err := data.Add(ctx, e)
+				if err != nil {
+					return report, err
+				}
+				outcome.ID = created.ID
+			}
+			report.Created = append(report.Created, outcome)
+
+		default:
+			outcome.ID = matches[0].ID
+			// The record is merged into the exhibition as stored when it's
+			// written, so a write since GetAll isn't undone.
+			var skipped *[]Outcome
+			merge := func(current data.Exhibition) (data.Exhibition, error) {
+				incoming := rec.apply(current, opts.Variants)
+				outcome.Changes = diff(current, incoming)
+				switch {
+				case len(outcome.Changes) == 0:
+					skipped = &report.Unchanged
+				case !opts.Overwrite:
+					outcome.Reason = "the stored exhibition differs; import with overwrite to replace it"
+					skipped = &report.Conflicts
+				default:
+					if err := validate(opts, incoming); err != nil {
+						outcome.Reason = err.Error()
+						skipped = &report.Invalid
+					}
+				}
+				if skipped != nil {
+					return data.Exhibition{}, errSkipped
+				}
+				return incoming, nil
+			}
+			var err error
+			if opts.DryRun {
+				_, err = merge(matches[0])
+			} else {
+				_, err = data.Modify(ctx, outcome.ID, merge)
+			}
+			switch {
+			case skipped != nil:
+				*skipped = append(*skipped, outcome)
+				continue
+			case errors.Is(err, data.ErrNotFound):
+				outcome.Reason = "the stored exhibition was deleted during the import"
+				report.Conflicts = append(report.Conflicts, outcome)
+				continue
+			case err != nil:
+				return report, err
+			}
+			report.Updated = append(report.Updated, outcome)
+		}
+	}
+	return report, nil
+}
+
+func matchKey(matchBy, id, title string) string {
+	if matchBy == MatchByID {
+		return id
+	}
+	return strings.ToLower(strings.TrimSpace(title))
+}
+
+func validate(opts Options, e data.Exhibition) error {
+	if opts.Validate == nil {
+		return nil
+	}
+	return opts.Validate(e)
+}
+
+func diff(current, incoming data.Exhibition) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, FieldChange{Field: field, Current: a, Incoming: b})
+		}
+	}
+	add("Slug", current.Slug, incoming.Slug)
+	add("Title", current.Title, incoming.Title)
+	add("Description", current.Description, incoming.Description)
+	add("Image", current.Image, incoming.Image)
+	add("Color", current.Color, incoming.Color)
+	add("CurrentlyOpened", current.CurrentlyOpened, incoming.CurrentlyOpened)
+	add("Schedule", current.Schedule, incoming.Schedule)
+	add("Entry", current.Entry, incoming.Entry)
+	add("Translations", current.Translations, incoming.Translations)
+	return changes
+}
@@ -0,0 +1,183 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// Record is one imported exhibition. Fields missing from the file are nil,
+// so merging never blanks out what the store already knows; data.json for
+// instance has no Color or CurrentlyOpened. Every stored field can be
+// imported but Variants, which are generated from the Image.
+type Record struct {
+	ID              *string
+	Slug            *string
+	Title           *string
+	Description     *string
+	Image           *string
+	Color           *string
+	CurrentlyOpened *bool
+	Schedule        *data.Schedule
+	Entry           *data.TimedEntry
+	Translations    map[string]data.Translation
+}
+
+// apply copies the fields present in the record onto e. A new image gets
+// the copies variants finds for it, when it's set.
+func (r Record) apply(e data.Exhibition, variants func(string) *data.ImageVariants) data.Exhibition {
+	set := func(target *string, value *string) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	set(&e.Slug, r.Slug)
+	set(&e.Title, r.Title)
+	set(&e.Description, r.Description)
+	if r.Image != nil && *r.Image != e.Image {
+		// The generated copies belong to the old image.
+		e.Image = *r.Image
+		e.Variants = nil
+		if variants != nil {
+			e.Variants = variants(e.Image)
+		}
+	}
+	set(&e.Color, r.Color)
+	if r.CurrentlyOpened != nil {
+		e.CurrentlyOpened = *r.CurrentlyOpened
+	}
+	if r.Schedule != nil {
+		e.Schedule = r.Schedule
+	}
+	if r.Entry != nil {
+		e.Entry = r.Entry
+	}
+	if r.Translations != nil {
+		e.Translations = r.Translations
+	}
+	return e
+}
+
+func (r Record) title() string {
+	if r.Title == nil {
+		return ""
+	}
+	return *r.Title
+}
+
+func (r Record) id() string {
+	if r.ID == nil {
+		return ""
+	}
+	return *r.ID
+}
+
+// ReadJSON reads an array of exhibitions. Keys are matched without regard
+// to case, so both the API's "Title" and data.json's "title" work.
+func ReadJSON(r io.Reader) ([]Record, error) {
+	var records []Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("reading JSON: %w", err)
+	}
+	return records, nil
+}
+
+// ReadCSV reads exhibitions from a CSV file whose first row names the
+// columns, like "title,description,image,color,currentlyOpened". The
+// schedule, entry and translations columns hold JSON, as WriteCSV does.
+func ReadCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the CSV header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "id", "slug", "title", "description", "image", "color", "currentlyopened",
+			"schedule", "entry", "translations":
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q", header[i])
+		}
+		columns[i] = name
+	}
+
+	var records []Record
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rec Record
+		for i, value := range row {
+			value := value
+			switch columns[i] {
+			case "id":
+				rec.ID = &value
+			case "slug":
+				rec.Slug = &value
+			case "title":
+				rec.Title = &value
+			case "description":
+				rec.Description = &value
+			case "image":
+				rec.Image = &value
+			case "color":
+				rec.Color = &value
+			case "currentlyopened":
+				if value == "" {
+					continue
+				}
+				opened, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: currentlyOpened must be true or false, got %q", line, value)
+				}
+				rec.CurrentlyOpened = &opened
+			case "schedule":
+				err = unmarshalCell(value, &rec.Schedule)
+			case "entry":
+				err = unmarshalCell(value, &rec.Entry)
+			case "translations":
+				err = unmarshalCell(value, &rec.Translations)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", line, header[i], err)
+			}
+		}
+		records = append(records, rec)
+	}
+}
+
+// unmarshalCell decodes the JSON of a CSV cell into target. An empty cell
+// leaves it nil.
+func unmarshalCell(value string, target any) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(value), target)
+}
+
+// ReadFile reads a .csv file as CSV and anything else as JSON.
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ReadCSV(file)
+	}
+	return ReadJSON(file)
+}
@@ -0,0 +1,120 @@
+package transfer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+func TestMergeByTitleKeepsMissingFields(t *testing.T) {
+	data.Use(data.NewMemoryRepository())
+	stored, _ := data.Add(context.Background(), data.Exhibition{Title: "Aristotle", Description: "old", Image: "aristotle.png", Color: "blue"})
+
+	// data.json style: lowercase keys and no color
+	records, err := ReadJSON(strings.NewReader(`[
+		{"title": "aristotle", "description": "new", "image": "aristotle.png"},
+		{"title": "Sea Monsters", "description": "deep", "image": "sea-monsters.png"},
+		{"title": "Sea Monsters", "description": "again", "image": "sea-monsters.png"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Merge(context.Background(), records, Options{MatchBy: MatchByTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Conflicts) != 2 || len(report.Created) != 1 {
+		t.Fatalf("got %+v, want the changed and the duplicate rows as conflicts", report)
+	}
+	if got, _ := data.Get(stored.ID); got.Description != "old" {
+		t.Errorf("got %q, want conflicts left alone without overwrite", got.Description)
+	}
+
+	report, _ = Merge(context.Background(), records[:1], Options{MatchBy: MatchByTitle, Overwrite: true})
+	got, _ := data.Get(stored.ID)
+	if len(report.Updated) != 1 || got.Description != "new" || got.Color != "blue" {
+		t.Errorf("got %+v, want the description replaced and the color kept", got)
+	}
+}
+
+func TestMergeSetsEveryStoredField(t *testing.T) {
+	data.Use(data.NewMemoryRepository())
+	stored, _ := data.Add(context.Background(), data.Exhibition{Title: "Amber", Description: "Fossil resin", Image: "amber.png"})
+
+	records, err := ReadCSV(strings.NewReader("title,schedule,entry,translations\n" +
+		`Amber,"{""Opens"":""2020-01-01""}","{""SlotMinutes"":30,""Capacity"":10}","{""pt"":{""Title"":""Âmbar""}}"` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Merge(context.Background(), records, Options{MatchBy: MatchByTitle, Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Updated) != 1 || len(report.Updated[0].Changes) != 3 {
+		t.Fatalf("got %+v, want the schedule, entry and translations changed", report)
+	}
+	got, _ := data.Get(stored.ID)
+	if got.Schedule == nil || got.Entry == nil || got.Translations["pt"].Title != "Âmbar" {
+		t.Fatalf("got %+v", got)
+	}
+
+	// What's exported comes back unchanged.
+	var exported strings.Builder
+	if err := WriteCSV(&exported, data.GetAll()); err != nil {
+		t.Fatal(err)
+	}
+	records, err = ReadCSV(strings.NewReader(exported.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, _ = Merge(context.Background(), records, Options{MatchBy: MatchByID})
+	if len(report.Unchanged) != 1 {
+		t.Errorf("got %+v, want the export unchanged", report)
+	}
+}
+
+func TestMergeKeepsWritesMadeDuringTheImport(t *testing.T) {
+	data.Use(data.NewMemoryRepository())
+	ctx := context.Background()
+	stored, _ := data.Add(ctx, data.Exhibition{Title: "Amber", Description: "old", Image: "amber.png"})
+
+	records, err := ReadJSON(strings.NewReader(`[
+		{"title": "Jade", "description": "Green stone", "image": "jade.png"},
+		{"title": "Amber", "description": "new", "image": "uploads/amber.png"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := false
+	report, err := Merge(ctx, records, Options{
+		MatchBy:   MatchByTitle,
+		Overwrite: true,
+		// Validating the first record, a curator saves Amber's color.
+		Validate: func(e data.Exhibition) error {
+			if !written {
+				written = true
+				current, _ := data.Get(stored.ID)
+				current.Color = "orange"
+				_, err := data.Update(ctx, current)
+				return err
+			}
+			return nil
+		},
+		Variants: func(image string) *data.ImageVariants {
+			return &data.ImageVariants{Original: "/gallery/" + image}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := data.Get(stored.ID)
+	if len(report.Updated) != 1 || got.Description != "new" || got.Color != "orange" {
+		t.Errorf("got %+v, want the import merged with the color saved meanwhile", got)
+	}
+	if got.Variants == nil || got.Variants.Original != "/gallery/uploads/amber.png" {
+		t.Errorf("got variants %+v, want those of the new image", got.Variants)
+	}
+}
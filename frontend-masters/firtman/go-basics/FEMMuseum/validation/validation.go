@@ -0,0 +1,180 @@
+package validation
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/gallery"
+	"frontendmasters.com/go/museum/i18n"
+)
+
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 5000
+)
+
+// Palette lists the colors the frontend knows how to draw an exhibition with.
+var Palette = []string{"red", "orange", "yellow", "green", "blue", "purple"}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects every problem found in a value, so clients can fix them
+// all at once instead of one per round trip.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *Errors) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+type Validator struct {
+	gallery fs.FS
+}
+
+// New returns a Validator checking images against gallery. A nil gallery
+// skips the existence check.
+func New(gallery fs.FS) *Validator {
+	return &Validator{gallery: gallery}
+}
+
+// Exhibition returns Errors describing everything wrong with e, or nil.
+func (v *Validator) Exhibition(e data.Exhibition) error {
+	var errs Errors
+	required(&errs, "Title", e.Title, MaxTitleLength)
+	required(&errs, "Description", e.Description, MaxDescriptionLength)
+
+	if e.Color != "" && !slices.Contains(Palette, e.Color) {
+		errs.add("Color", "must be one of %s", strings.Join(Palette, ", "))
+	}
+	if e.Slug != "" && data.Slugify(e.Slug) != e.Slug {
+		errs.add("Slug", "may only contain lowercase letters, digits and dashes")
+	}
+
+	switch {
+	case strings.TrimSpace(e.Image) == "":
+		errs.add("Image", "is required")
+	case !fs.ValidPath(e.Image) || e.Image == ".":
+		errs.add("Image", "must be a file name inside the gallery")
+	case !gallery.IsOriginal(e.Image):
+		errs.add("Image", "must be a PNG, JPEG or GIF image and not a generated copy")
+	case v.gallery != nil && !v.imageExists(e.Image):
+		errs.add("Image", "%q doesn't exist in the gallery", e.Image)
+	}
+
+	if e.Schedule != nil {
+		schedule(&errs, *e.Schedule)
+	}
+	if e.Entry != nil {
+		switch {
+		case e.Schedule == nil || len(e.Schedule.Hours) == 0:
+			errs.add("Entry", "needs a Schedule with opening Hours to cut into slots")
+		case e.Entry.SlotMinutes < 5 || e.Entry.SlotMinutes > 24*60:
+			errs.add("Entry.SlotMinutes", "must be between 5 and 1440")
+		}
+		if e.Entry.Capacity < 1 {
+			errs.add("Entry.Capacity", "must be at least 1")
+		}
+	}
+
+	for _, tag := range slices.Sorted(maps.Keys(e.Translations)) {
+		field := "Translations[" + tag + "]"
+		switch canonical, ok := i18n.Canonical(tag); {
+		case !ok:
+			errs.add(field, "must be keyed by a language tag like pt-BR")
+		case canonical != tag:
+			errs.add(field, "must be keyed by %s", canonical)
+		case tag == i18n.Base:
+			errs.add(field, "is the language of Title and Description")
+		}
+		t := e.Translations[tag]
+		if t.Title == "" && t.Description == "" {
+			errs.add(field, "must have a Title or a Description")
+		}
+		if utf8.RuneCountInString(t.Title) > MaxTitleLength {
+			errs.add(field+".Title", "must be at most %d characters", MaxTitleLength)
+		}
+		if utf8.RuneCountInString(t.Description) > MaxDescriptionLength {
+			errs.add(field+".Description", "must be at most %d characters", MaxDescriptionLength)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func schedule(errs *Errors, s data.Schedule) {
+	if s.Opens != "" && !isDate(s.Opens) {
+		errs.add("Schedule.Opens", "must be a date like 2024-05-31")
+	}
+	if s.Closes != "" && !isDate(s.Closes) {
+		errs.add("Schedule.Closes", "must be a date like 2024-05-31")
+	}
+	if isDate(s.Opens) && isDate(s.Closes) && s.Closes < s.Opens {
+		errs.add("Schedule.Closes", "must not be before Opens")
+	}
+	for i, h := range s.Hours {
+		field := fmt.Sprintf("Schedule.Hours[%d]", i)
+		if !slices.Contains(data.Weekdays, h.Day) {
+			errs.add(field+".Day", "must be one of %s", strings.Join(data.Weekdays, ", "))
+		}
+		open, close := isTime(h.Open), isTime(h.Close)
+		if !open {
+			errs.add(field+".Open", "must be a time like 09:30")
+		}
+		if !close {
+			errs.add(field+".Close", "must be a time like 17:00")
+		}
+		if open && close && h.Close <= h.Open {
+			errs.add(field+".Close", "must be after Open")
+		}
+	}
+	for i, day := range s.Closures {
+		if !isDate(day) {
+			errs.add(fmt.Sprintf("Schedule.Closures[%d]", i), "must be a date like 2024-12-25")
+		}
+	}
+}
+
+// isDate and isTime also require the zero padding, since schedules are
+// compared as strings.
+func isDate(value string) bool {
+	t, err := time.Parse(data.DateFormat, value)
+	return err == nil && t.Format(data.DateFormat) == value
+}
+
+func isTime(value string) bool {
+	t, err := time.Parse(data.TimeFormat, value)
+	return err == nil && t.Format(data.TimeFormat) == value
+}
+
+func (v *Validator) imageExists(name string) bool {
+	info, err := fs.Stat(v.gallery, name)
+	return err == nil && !info.IsDir()
+}
+
+func required(errs *Errors, field, value string, max int) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		errs.add(field, "is required")
+	case utf8.RuneCountInString(value) > max:
+		errs.add(field, "must be at most %d characters", max)
+	}
+}
@@ -0,0 +1,154 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+func TestExhibition(t *testing.T) {
+	v := New(fstest.MapFS{
+		"amber.png":       {Data: []byte("png")},
+		"amber-thumb.png": {Data: []byte("png")},
+		"data.json":       {Data: []byte("[]")},
+		"rooms/a.png":     {Data: []byte("png")},
+		"old.png/a.png":   {Data: []byte("png")},
+	})
+	hours := []data.OpeningHours{{Day: "monday", Open: "09:00", Close: "17:00"}}
+
+	for _, test := range []struct {
+		name   string
+		change func(e *data.Exhibition)
+		want   Errors // nil when e is valid
+	}{
+		{"valid", func(e *data.Exhibition) {}, nil},
+		{"image in a folder", func(e *data.Exhibition) { e.Image = "rooms/a.png" }, nil},
+
+		{"blank title", func(e *data.Exhibition) { e.Title = "  " },
+			Errors{{"Title", "is required"}}},
+		{"long title", func(e *data.Exhibition) { e.Title = strings.Repeat("é", MaxTitleLength+1) },
+			Errors{{"Title", "must be at most 200 characters"}}},
+		{"title at the limit, counted in characters", func(e *data.Exhibition) { e.Title = strings.Repeat("é", MaxTitleLength) },
+			nil},
+		{"missing description", func(e *data.Exhibition) { e.Description = "" },
+			Errors{{"Description", "is required"}}},
+		{"long description", func(e *data.Exhibition) { e.Description = strings.Repeat("a", MaxDescriptionLength+1) },
+			Errors{{"Description", "must be at most 5000 characters"}}},
+
+		{"color out of the palette", func(e *data.Exhibition) { e.Color = "pink" },
+			Errors{{"Color", "must be one of red, orange, yellow, green, blue, purple"}}},
+		{"slug with capitals", func(e *data.Exhibition) { e.Slug = "Amber" },
+			Errors{{"Slug", "may only contain lowercase letters, digits and dashes"}}},
+
+		{"missing image", func(e *data.Exhibition) { e.Image = "" },
+			Errors{{"Image", "is required"}}},
+		{"image outside the gallery", func(e *data.Exhibition) { e.Image = "../secret.png" },
+			Errors{{"Image", "must be a file name inside the gallery"}}},
+		{"image not in the gallery", func(e *data.Exhibition) { e.Image = "jade.png" },
+			Errors{{"Image", `"jade.png" doesn't exist in the gallery`}}},
+		{"image is a folder", func(e *data.Exhibition) { e.Image = "old.png" },
+			Errors{{"Image", `"old.png" doesn't exist in the gallery`}}},
+		{"not an image", func(e *data.Exhibition) { e.Image = "data.json" },
+			Errors{{"Image", "must be a PNG, JPEG or GIF image and not a generated copy"}}},
+		{"generated copy", func(e *data.Exhibition) { e.Image = "amber-thumb.png" },
+			Errors{{"Image", "must be a PNG, JPEG or GIF image and not a generated copy"}}},
+		{"upper case extension", func(e *data.Exhibition) { e.Image = "AMBER.PNG" },
+			Errors{{"Image", `"AMBER.PNG" doesn't exist in the gallery`}}},
+
+		{"schedule", func(e *data.Exhibition) {
+			e.Schedule = &data.Schedule{Opens: "2024-05-01", Closes: "2024-05-31", Hours: hours, Closures: []string{"2024-05-20"}}
+		}, nil},
+		{"unpadded dates", func(e *data.Exhibition) { e.Schedule = &data.Schedule{Opens: "2024-5-1", Closes: "31/05/2024"} },
+			Errors{{"Schedule.Opens", "must be a date like 2024-05-31"}, {"Schedule.Closes", "must be a date like 2024-05-31"}}},
+		{"closes before it opens", func(e *data.Exhibition) { e.Schedule = &data.Schedule{Opens: "2024-05-31", Closes: "2024-05-01"} },
+			Errors{{"Schedule.Closes", "must not be before Opens"}}},
+		{"bad hours", func(e *data.Exhibition) {
+			e.Schedule = &data.Schedule{Hours: []data.OpeningHours{{Day: "Monday", Open: "9:00", Close: "24:00"}}}
+		}, Errors{
+			{"Schedule.Hours[0].Day", "must be one of sunday, monday, tuesday, wednesday, thursday, friday, saturday"},
+			{"Schedule.Hours[0].Open", "must be a time like 09:30"},
+			{"Schedule.Hours[0].Close", "must be a time like 17:00"},
+		}},
+		{"closes before it opens in the day", func(e *data.Exhibition) {
+			e.Schedule = &data.Schedule{Hours: []data.OpeningHours{{Day: "monday", Open: "17:00", Close: "09:00"}}}
+		}, Errors{{"Schedule.Hours[0].Close", "must be after Open"}}},
+		{"bad closure", func(e *data.Exhibition) { e.Schedule = &data.Schedule{Closures: []string{"2024-12-25", "christmas"}} },
+			Errors{{"Schedule.Closures[1]", "must be a date like 2024-12-25"}}},
+
+		{"timed entry", func(e *data.Exhibition) {
+			e.Schedule = &data.Schedule{Hours: hours}
+			e.Entry = &data.TimedEntry{SlotMinutes: 30, Capacity: 20}
+		}, nil},
+		{"timed entry without hours", func(e *data.Exhibition) { e.Entry = &data.TimedEntry{SlotMinutes: 30, Capacity: 20} },
+			Errors{{"Entry", "needs a Schedule with opening Hours to cut into slots"}}},
+		{"bad slots and capacity", func(e *data.Exhibition) {
+			e.Schedule = &data.Schedule{Hours: hours}
+			e.Entry = &data.TimedEntry{SlotMinutes: 4}
+		}, Errors{{"Entry.SlotMinutes", "must be between 5 and 1440"}, {"Entry.Capacity", "must be at least 1"}}},
+
+		{"translations", func(e *data.Exhibition) {
+			e.Translations = map[string]data.Translation{"pt-BR": {Title: "Âmbar"}, "de": {Description: "Fossiles Harz"}}
+		}, nil},
+		{"bad translations", func(e *data.Exhibition) {
+			e.Translations = map[string]data.Translation{
+				"pt-br":   {Title: "Âmbar"},
+				"english": {Title: "Amber"},
+				"en":      {Title: "Amber"},
+				"de":      {},
+				"fr":      {Title: strings.Repeat("a", MaxTitleLength+1), Description: strings.Repeat("a", MaxDescriptionLength+1)},
+			}
+		}, Errors{
+			// in the order of the tags
+			{"Translations[de]", "must have a Title or a Description"},
+			{"Translations[en]", "is the language of Title and Description"},
+			{"Translations[english]", "must be keyed by a language tag like pt-BR"},
+			{"Translations[fr].Title", "must be at most 200 characters"},
+			{"Translations[fr].Description", "must be at most 5000 characters"},
+			{"Translations[pt-br]", "must be keyed by pt-BR"},
+		}},
+
+		{"every problem at once", func(e *data.Exhibition) { *e = data.Exhibition{Color: "pink"} },
+			Errors{{"Title", "is required"}, {"Description", "is required"}, {"Color", "must be one of red, orange, yellow, green, blue, purple"}, {"Image", "is required"}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			e := data.Exhibition{Title: "Amber", Description: "Fossil resin", Image: "amber.png", Color: "orange", Slug: "amber"}
+			test.change(&e)
+			err := v.Exhibition(e)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want it valid", err)
+				}
+				return
+			}
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("got %v, want Errors", err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("error %d: got %q, want %q", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWithoutGalleryImagesAreNotLookedUp(t *testing.T) {
+	e := data.Exhibition{Title: "Amber", Description: "Fossil resin", Image: "amber.png"}
+	if err := New(nil).Exhibition(e); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestErrorsMessage(t *testing.T) {
+	errs := Errors{{"Title", "is required"}, {"Schedule.Hours[0].Open", "must be a time like 09:30"}}
+	if got, want := errs.Error(), "Title: is required; Schedule.Hours[0].Open: must be a time like 09:30"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,563 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+const (
+	// logSize is how many delivered deliveries are kept for the delivery
+	// log; pending and failed ones are always kept.
+	logSize = 500
+	// scheduleCheck is how often schedules are checked for exhibitions
+	// opening or closing on their own.
+	scheduleCheck = time.Minute
+	// maxResponseBytes is how much of a response is read before the
+	// connection is dropped.
+	maxResponseBytes = 64 << 10
+)
+
+type Options struct {
+	// Client sends the deliveries; by default one giving up after 10s.
+	Client *http.Client
+	// MaxAttempts is how many times a delivery is tried before it becomes a
+	// dead letter; 8 by default.
+	MaxAttempts int
+	// The wait before retrying doubles from MinBackoff up to MaxBackoff,
+	// 30s and 1h by default, with some jitter.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Workers is how many deliveries are sent at once; 4 by default.
+	Workers int
+}
+
+// Dispatcher turns store changes into deliveries and sends them in the
+// background. Deliveries are written to a journal before Notify returns,
+// so none are lost to a restart.
+type Dispatcher struct {
+	opts Options
+
+	mu            sync.Mutex
+	subscriptions []Subscription
+	deliveries    map[string]*Delivery
+	delivered     []string // IDs of the delivered deliveries, oldest first
+	inFlight      map[string]bool
+	tracked       map[string]data.Exhibition // to notice openings and closings
+	store         *store                     // nil when in memory only
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a Dispatcher keeping everything in memory.
+func New(opts Options) *Dispatcher {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(time.Hour, opts.MinBackoff)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		opts:       opts,
+		deliveries: make(map[string]*Delivery),
+		inFlight:   make(map[string]bool),
+		tracked:    make(map[string]data.Exhibition),
+		wake:       make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Open returns a Dispatcher keeping its subscriptions in path and its
+// deliveries in path + ".queue".
+func Open(path string, opts Options) (*Dispatcher, error) {
+	d := New(opts)
+	s, subscriptions, deliveries, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	d.store = s
+	d.subscriptions = subscriptions
+	for _, delivery := range deliveries {
+		d.add(delivery)
+	}
+	return d, nil
+}
+
+// Start sends the queued deliveries, and the new ones as they come, until
+// Close is called.
+func (d *Dispatcher) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.run()
+	}()
+}
+
+// Close stops sending and waits for the deliveries being sent. Those
+// interrupted are sent again by the next process.
+func (d *Dispatcher) Close() error {
+	d.cancel()
+	d.wg.Wait()
+	if d.store == nil {
+		return nil
+	}
+	return d.store.close()
+}
+
+// Track remembers whether exhibitions are open, so their opening or
+// closing is noticed. Call it with the whole store before subscribing.
+func (d *Dispatcher) Track(list []data.Exhibition) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range list {
+		d.tracked[e.ID] = e
+	}
+}
+
+// Notify queues deliveries for a store change; pass it to data.Subscribe.
+func (d *Dispatcher) Notify(c data.Change) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e := c.Exhibition
+	previous, known := d.tracked[e.ID]
+	switch c.Type {
+	case data.Created:
+		d.tracked[e.ID] = e
+		d.enqueue(ExhibitionCreated, e, c.Time)
+		if isOpen(e, c.Time) {
+			d.enqueue(ExhibitionOpened, e, c.Time)
+		}
+	case data.Updated:
+		d.tracked[e.ID] = e
+		d.enqueue(ExhibitionUpdated, e, c.Time)
+		if opened := isOpen(e, c.Time); known && opened != isOpen(previous, c.Time) {
+			d.enqueue(openedOrClosed(opened), e, c.Time)
+		}
+	case data.Deleted:
+		delete(d.tracked, e.ID)
+		d.enqueue(ExhibitionDeleted, e, c.Time)
+	}
+}
+
+// checkSchedules sends opened and closed events for the exhibitions whose
+// schedule opened or closed them since the last check.
+func (d *Dispatcher) checkSchedules(last, t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.tracked {
+		if e.Schedule == nil {
+			continue
+		}
+		if opened := e.Schedule.OpenAt(t); opened != e.Schedule.OpenAt(last) {
+			d.enqueue(openedOrClosed(opened), e, t)
+		}
+	}
+}
+
+func isOpen(e data.Exhibition, t time.Time) bool {
+	if e.Schedule != nil {
+		return e.Schedule.OpenAt(t)
+	}
+	return e.CurrentlyOpened
+}
+
+func openedOrClosed(opened bool) string {
+	if opened {
+		return ExhibitionOpened
+	}
+	return ExhibitionClosed
+}
+
+// enqueue creates a delivery of the event for every subscription wanting
+// it. d.mu must be held.
+func (d *Dispatcher) enqueue(eventType string, e data.Exhibition, t time.Time) {
+	e.CurrentlyOpened = isOpen(e, t)
+	event := Event{ID: newID("evt_", 12), Type: eventType, Time: t.UTC(), Exhibition: e}
+	queued := false
+	for _, s := range d.subscriptions {
+		if !s.wants(eventType) {
+			continue
+		}
+		delivery := &Delivery{
+			ID:           newID("dlv_", 12),
+			Subscription: s.ID,
+			Event:        event,
+			Status:       Pending,
+			NextAttempt:  t,
+		}
+		d.add(delivery)
+		d.save(delivery)
+		queued = true
+	}
+	if queued {
+		d.signal()
+	}
+}
+
+// add puts a delivery in memory, forgetting the oldest delivered ones
+// beyond logSize. d.mu must be held.
+func (d *Dispatcher) add(delivery *Delivery) {
+	d.deliveries[delivery.ID] = delivery
+	if delivery.Status == Delivered {
+		d.delivered = append(d.delivered, delivery.ID)
+	}
+	for len(d.delivered) > logSize {
+		delete(d.deliveries, d.delivered[0])
+		d.delivered = d.delivered[1:]
+	}
+}
+
+// save writes a delivery to the journal. d.mu must be held.
+func (d *Dispatcher) save(delivery *Delivery) {
+	if d.store == nil {
+		return
+	}
+	if err := d.store.append(delivery); err != nil {
+		log.Printf("Couldn't write webhook delivery %s: %v", delivery.ID, err)
+		return
+	}
+	if d.store.needsCompaction(len(d.deliveries)) {
+		// Notify runs while the store holds off every other write, so
+		// the rewrite is left to the run loop.
+		d.signal()
+	}
+}
+
+// compact rewrites the journal when it needs it. The deliveries are only
+// locked while they're encoded and while the new journal is put in place.
+func (d *Dispatcher) compact() {
+	d.mu.Lock()
+	if d.store == nil || !d.store.needsCompaction(len(d.deliveries)) {
+		d.mu.Unlock()
+		return
+	}
+	content, err := d.store.snapshot(d.all())
+	d.mu.Unlock()
+	if err != nil {
+		log.Printf("Couldn't compact the webhook queue: %v", err)
+		return
+	}
+
+	tmp, err := d.store.writeSnapshot(content)
+	d.mu.Lock()
+	if err == nil {
+		err = d.store.swap(tmp)
+	} else {
+		d.store.abort()
+	}
+	d.mu.Unlock()
+	if err != nil {
+		log.Printf("Couldn't compact the webhook queue: %v", err)
+	}
+}
+
+func (d *Dispatcher) all() []*Delivery {
+	list := make([]*Delivery, 0, len(d.deliveries))
+	for _, delivery := range d.deliveries {
+		list = append(list, delivery)
+	}
+	return list
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) run() {
+	sem := make(chan struct{}, d.opts.Workers)
+	lastCheck := time.Now()
+	schedules := time.NewTicker(scheduleCheck)
+	defer schedules.Stop()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		d.compact()
+		due, next := d.due(time.Now())
+		for _, job := range due {
+			sem <- struct{}{}
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				defer func() { <-sem }()
+				attempt := d.send(job.delivery, job.subscription)
+				d.finish(job.delivery.ID, attempt)
+			}()
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.wake:
+		case <-timer.C:
+		case t := <-schedules.C:
+			d.checkSchedules(lastCheck, t)
+			lastCheck = t
+		}
+	}
+}
+
+type job struct {
+	delivery     Delivery
+	subscription Subscription
+}
+
+// due returns the deliveries to send now, marking them in flight, and when
+// the next one will be due.
+func (d *Dispatcher) due(t time.Time) (jobs []job, next time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, delivery := range d.deliveries {
+		if delivery.Status != Pending || d.inFlight[delivery.ID] {
+			continue
+		}
+		if delivery.NextAttempt.After(t) {
+			if next.IsZero() || delivery.NextAttempt.Before(next) {
+				next = delivery.NextAttempt
+			}
+			continue
+		}
+		i := slices.IndexFunc(d.subscriptions, func(s Subscription) bool { return s.ID == delivery.Subscription })
+		if i < 0 {
+			// The subscription was removed after the event was queued.
+			d.remove(delivery.ID)
+			continue
+		}
+		d.inFlight[delivery.ID] = true
+		jobs = append(jobs, job{delivery: *delivery, subscription: d.subscriptions[i]})
+	}
+	slices.SortFunc(jobs, func(a, b job) int { return a.delivery.Event.Time.Compare(b.delivery.Event.Time) })
+	return jobs, next
+}
+
+// remove forgets a delivery for good. d.mu must be held.
+func (d *Dispatcher) remove(id string) {
+	delete(d.deliveries, id)
+	d.save(&Delivery{ID: id, Status: removed})
+}
+
+func (d *Dispatcher) send(delivery Delivery, s Subscription) Attempt {
+	start := time.Now()
+	attempt := Attempt{Time: start.UTC()}
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "museum-webhooks/1")
+	req.Header.Set("X-Museum-Event", delivery.Event.Type)
+	req.Header.Set("X-Museum-Delivery", delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(s.Secret, start, body))
+	res, err := d.opts.Client.Do(req)
+	attempt.Duration = time.Since(start)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBytes))
+	res.Body.Close()
+	attempt.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("the receiver answered %s", res.Status)
+	}
+	return attempt
+}
+
+// finish records an attempt and decides what's next for the delivery.
+func (d *Dispatcher) finish(id string, attempt Attempt) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, id)
+	delivery, ok := d.deliveries[id]
+	if !ok {
+		return
+	}
+	if d.ctx.Err() != nil {
+		// Cut short by Close; it stays pending for the next process.
+		return
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	switch {
+	case attempt.Error == "":
+		delivery.Status = Delivered
+		delivery.NextAttempt = time.Time{}
+		d.add(delivery)
+	case len(delivery.Attempts) >= d.opts.MaxAttempts*(delivery.Retries+1):
+		delivery.Status = Failed
+		delivery.NextAttempt = time.Time{}
+		log.Printf("Webhook delivery %s to %s failed for good: %s", id, delivery.Subscription, attempt.Error)
+	default:
+		delivery.NextAttempt = time.Now().Add(d.backoff(len(delivery.Attempts) - d.opts.MaxAttempts*delivery.Retries))
+	}
+	d.save(delivery)
+	d.signal()
+}
+
+// backoff is how long to wait after the nth failed attempt.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	wait := d.opts.MaxBackoff
+	if n < 32 {
+		wait = min(d.opts.MinBackoff<<(n-1), d.opts.MaxBackoff)
+	}
+	// Spread out the retries of deliveries that failed together.
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// Subscriptions lists the subscriptions, without their secrets.
+func (d *Dispatcher) Subscriptions() []Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]Subscription, len(d.subscriptions))
+	for i, s := range d.subscriptions {
+		s.Secret = ""
+		list[i] = s
+	}
+	return list
+}
+
+func (d *Dispatcher) Subscription(id string) (Subscription, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.subscriptions {
+		if s.ID == id {
+			s.Secret = ""
+			return s, nil
+		}
+	}
+	return Subscription{}, ErrNotFound
+}
+
+// Subscribe adds a subscription and returns it with its ID and, when s
+// had none, a generated secret.
+func (d *Dispatcher) Subscribe(s Subscription) (Subscription, error) {
+	if err := Validate(s); err != nil {
+		return Subscription{}, err
+	}
+	s.ID = newID("wh_", 8)
+	if s.Secret == "" {
+		s.Secret = newID("whsec_", 24)
+	}
+	s.Created = time.Now().UTC()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subscriptions := append(slices.Clip(d.subscriptions), s)
+	if err := d.saveSubscriptions(subscriptions); err != nil {
+		return Subscription{}, err
+	}
+	d.subscriptions = subscriptions
+	return s, nil
+}
+
+// Unsubscribe removes a subscription; what was queued for it is dropped.
+func (d *Dispatcher) Unsubscribe(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := slices.IndexFunc(d.subscriptions, func(s Subscription) bool { return s.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	subscriptions := slices.Delete(slices.Clone(d.subscriptions), i, i+1)
+	if err := d.saveSubscriptions(subscriptions); err != nil {
+		return err
+	}
+	d.subscriptions = subscriptions
+	for _, delivery := range d.all() {
+		if delivery.Subscription == id && delivery.Status != Delivered {
+			d.remove(delivery.ID)
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) saveSubscriptions(list []Subscription) error {
+	if d.store == nil {
+		return nil
+	}
+	return d.store.writeSubscriptions(list)
+}
+
+// Deliveries returns the recent deliveries to a subscription, newest first.
+func (d *Dispatcher) Deliveries(subscription string) ([]Delivery, error) {
+	if _, err := d.Subscription(subscription); err != nil {
+		return nil, err
+	}
+	return d.list(func(delivery *Delivery) bool { return delivery.Subscription == subscription }), nil
+}
+
+// DeadLetters returns the deliveries that ran out of attempts, newest first.
+func (d *Dispatcher) DeadLetters() []Delivery {
+	return d.list(func(delivery *Delivery) bool { return delivery.Status == Failed })
+}
+
+func (d *Dispatcher) list(keep func(*Delivery) bool) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := []Delivery{}
+	for _, delivery := range d.deliveries {
+		if keep(delivery) {
+			copied := *delivery
+			copied.Attempts = slices.Clone(delivery.Attempts)
+			list = append(list, copied)
+		}
+	}
+	slices.SortFunc(list, func(a, b Delivery) int {
+		if c := b.Event.Time.Compare(a.Event.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return list
+}
+
+// Retry queues a dead letter again, with a fresh set of attempts.
+func (d *Dispatcher) Retry(id string) (Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery, ok := d.deliveries[id]
+	if !ok || delivery.Status != Failed {
+		return Delivery{}, ErrNotFound
+	}
+	delivery.Status = Pending
+	delivery.Retries++
+	delivery.NextAttempt = time.Now()
+	d.save(delivery)
+	d.signal()
+	copied := *delivery
+	copied.Attempts = slices.Clone(delivery.Attempts)
+	return copied, nil
+}
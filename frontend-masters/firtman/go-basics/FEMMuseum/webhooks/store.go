@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// compactEvery is how many journal lines may be written before the journal
+// is rewritten with only the deliveries still kept.
+const compactEvery = 1000
+
+// store keeps the subscriptions in a JSON file and the deliveries in a
+// journal of JSON lines, each holding the whole delivery after a change.
+// The last line about a delivery wins.
+type store struct {
+	path    string
+	journal *os.File
+	lines   int // written since the journal was last compacted
+
+	// While compacting, the lines appended since the snapshot are kept to
+	// be added to the new journal.
+	compacting bool
+	since      [][]byte
+}
+
+func openStore(path string) (*store, []Subscription, []*Delivery, error) {
+	s := &store{path: path}
+	subscriptions, err := s.readSubscriptions()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	deliveries, err := s.replay()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// Compacting right away starts a journal holding only what's kept.
+	if err := s.compact(deliveries); err != nil {
+		return nil, nil, nil, err
+	}
+	return s, subscriptions, deliveries, nil
+}
+
+func (s *store) queuePath() string {
+	return s.path + ".queue"
+}
+
+func (s *store) readSubscriptions() ([]Subscription, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Subscription
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	return list, nil
+}
+
+func (s *store) writeSubscriptions(list []Subscription) error {
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The file holds the secrets, so it's only readable by its owner.
+	return writeFile(s.path, append(content, '\n'), 0600)
+}
+
+func (s *store) replay() ([]*Delivery, error) {
+	byID := make(map[string]*Delivery)
+	var order []string
+	err := data.ReadJournal(s.queuePath(), func(delivery Delivery, _ int) error {
+		if delivery.Status == removed {
+			delete(byID, delivery.ID)
+			return nil
+		}
+		if _, ok := byID[delivery.ID]; !ok {
+			order = append(order, delivery.ID)
+		}
+		byID[delivery.ID] = &delivery
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []*Delivery
+	for _, id := range order {
+		if delivery, ok := byID[id]; ok {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+// append writes a delivery to the journal and syncs it, so it survives a
+// crash once append returns.
+func (s *store) append(delivery *Delivery) error {
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := s.journal.Write(line); err != nil {
+		return err
+	}
+	if err := s.journal.Sync(); err != nil {
+		return err
+	}
+	s.lines++
+	if s.compacting {
+		s.since = append(s.since, line)
+	}
+	return nil
+}
+
+// needsCompaction reports whether the journal has grown well past the
+// kept deliveries and isn't being compacted already.
+func (s *store) needsCompaction(kept int) bool {
+	return !s.compacting && s.lines > compactEvery && s.lines > 2*kept
+}
+
+// compact replaces the journal with one line per delivery.
+func (s *store) compact(deliveries []*Delivery) error {
+	content, err := s.snapshot(deliveries)
+	if err != nil {
+		return err
+	}
+	tmp, err := s.writeSnapshot(content)
+	if err != nil {
+		s.abort()
+		return err
+	}
+	return s.swap(tmp)
+}
+
+// Compacting is done in three steps, so that only the first and the last,
+// which are quick, need to hold off the writes to the journal: snapshot
+// encodes the deliveries, writeSnapshot writes them to a new journal and
+// swap adds what was appended in the meantime and puts it in place. Only
+// writeSnapshot may run without holding off the writes.
+
+func (s *store) snapshot(deliveries []*Delivery) ([]byte, error) {
+	deliveries = slices.Clone(deliveries)
+	slices.SortStableFunc(deliveries, func(a, b *Delivery) int { return a.Event.Time.Compare(b.Event.Time) })
+	var content []byte
+	for _, delivery := range deliveries {
+		line, err := json.Marshal(delivery)
+		if err != nil {
+			return nil, err
+		}
+		content = append(append(content, line...), '\n')
+	}
+	s.compacting = true
+	s.since = nil
+	return content, nil
+}
+
+func (s *store) writeSnapshot(content []byte) (*os.File, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".webhooks-*")
+	if err == nil {
+		if _, err = tmp.Write(content); err == nil {
+			err = tmp.Sync()
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// abort gives up a compaction that couldn't write its snapshot.
+func (s *store) abort() {
+	s.compacting = false
+	s.since = nil
+}
+
+func (s *store) swap(tmp *os.File) error {
+	s.compacting = false
+	defer os.Remove(tmp.Name())
+	var err error
+	for _, line := range s.since {
+		if _, err = tmp.Write(line); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.queuePath())
+	}
+	if err != nil {
+		return err
+	}
+	if s.journal != nil {
+		s.journal.Close()
+	}
+	journal, err := os.OpenFile(s.queuePath(), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.journal = journal
+	s.lines = len(s.since)
+	s.since = nil
+	return nil
+}
+
+func (s *store) close() error {
+	return s.journal.Close()
+}
+
+// writeFile replaces name atomically, so a crash leaves either the old
+// content or the new one.
+func writeFile(name string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".webhooks-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
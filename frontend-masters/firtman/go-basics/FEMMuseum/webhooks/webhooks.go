@@ -0,0 +1,116 @@
+// Package webhooks tells partner sites about exhibitions being created,
+// changed, deleted, opened and closed, by POSTing signed JSON to the URLs
+// they subscribed.
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+	"frontendmasters.com/go/museum/validation"
+)
+
+const (
+	ExhibitionCreated = "exhibition.created"
+	ExhibitionUpdated = "exhibition.updated"
+	ExhibitionDeleted = "exhibition.deleted"
+	// Opened and closed are sent when an exhibition is opened or closed by
+	// an edit or by its schedule.
+	ExhibitionOpened = "exhibition.opened"
+	ExhibitionClosed = "exhibition.closed"
+)
+
+// EventTypes lists every event a subscription can ask for.
+var EventTypes = []string{ExhibitionCreated, ExhibitionUpdated, ExhibitionDeleted, ExhibitionOpened, ExhibitionClosed}
+
+var ErrNotFound = errors.New("webhooks: not found")
+
+// Subscription asks for events to be POSTed to URL. Secret signs them; it's
+// only shown when the subscription is created.
+type Subscription struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events,omitempty"` // every event when empty
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+func (s Subscription) wants(eventType string) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, eventType)
+}
+
+// Event is the JSON body of a delivery. ID is the same for every
+// subscription it's sent to, so receivers can drop duplicates.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Time       time.Time       `json:"time"`
+	Exhibition data.Exhibition `json:"exhibition"`
+}
+
+const (
+	Pending   = "pending"
+	Delivered = "delivered"
+	// Failed deliveries ran out of attempts; they're the dead letters and
+	// can be retried by hand.
+	Failed = "failed"
+	// removed marks a delivery dropped from the journal.
+	removed = "removed"
+)
+
+// Delivery is one event on its way to one subscription.
+type Delivery struct {
+	ID           string    `json:"id"`
+	Subscription string    `json:"subscription"`
+	Event        Event     `json:"event"`
+	Status       string    `json:"status"`
+	Attempts     []Attempt `json:"attempts"`
+	NextAttempt  time.Time `json:"nextAttempt,omitzero"`
+	// Retries counts the times it was retried by hand after failing.
+	Retries int `json:"retries,omitempty"`
+}
+
+// Attempt records one try at sending a delivery.
+type Attempt struct {
+	Time       time.Time     `json:"time"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"durationNs"`
+}
+
+// Validate checks a subscription before it's added.
+func Validate(s Subscription) error {
+	var errs validation.Errors
+	u, err := url.Parse(s.URL)
+	switch {
+	case strings.TrimSpace(s.URL) == "":
+		errs = append(errs, validation.FieldError{Field: "url", Message: "is required"})
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		errs = append(errs, validation.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+	for i, event := range s.Events {
+		if !slices.Contains(EventTypes, event) {
+			errs = append(errs, validation.FieldError{
+				Field:   "events[" + strconv.Itoa(i) + "]",
+				Message: "must be one of " + strings.Join(EventTypes, ", "),
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func newID(prefix string, size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
@@ -0,0 +1,260 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"frontendmasters.com/go/museum/data"
+)
+
+// receiver answers the statuses in turn, then 200, checking every
+// signature with secret.
+func receiver(t *testing.T, secret string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifySignature(secret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("delivery %s: %v", r.Header.Get("X-Museum-Delivery"), err)
+		}
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func waitFor(t *testing.T, d *Dispatcher, subscription string, status string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		list, err := d.Deliveries(subscription)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) > 0 && list[0].Status == status {
+			return list[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no delivery became %s", status)
+	return Delivery{}
+}
+
+var fast = Options{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, MaxAttempts: 3}
+
+func TestDeliveriesAreRetriedWithBackoff(t *testing.T) {
+	server, calls := receiver(t, "s3cret", http.StatusInternalServerError, http.StatusServiceUnavailable)
+	d := New(fast)
+	d.Start()
+	defer d.Close()
+	s, err := d.Subscribe(Subscription{URL: server.URL, Secret: "s3cret", Events: []string{ExhibitionCreated}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Notify(data.Change{Type: data.Created, Exhibition: data.Exhibition{ID: "1", Title: "Amber", CurrentlyOpened: true}, Time: time.Now()})
+	delivery := waitFor(t, d, s.ID, Delivered)
+	if calls.Load() != 3 || len(delivery.Attempts) != 3 {
+		t.Fatalf("got %d calls and attempts %+v", calls.Load(), delivery.Attempts)
+	}
+	for i, want := range []int{500, 503, 200} {
+		if got := delivery.Attempts[i].StatusCode; got != want {
+			t.Errorf("attempt %d got %d, want %d", i, got, want)
+		}
+	}
+	// The subscription didn't ask for openings.
+	if list, _ := d.Deliveries(s.ID); len(list) != 1 || list[0].Event.Type != ExhibitionCreated {
+		t.Fatalf("got %+v", list)
+	}
+}
+
+func TestDeadLettersCanBeRetried(t *testing.T) {
+	server, _ := receiver(t, "s3cret", 500, 500, 500)
+	d := New(fast)
+	d.Start()
+	defer d.Close()
+	s, _ := d.Subscribe(Subscription{URL: server.URL, Secret: "s3cret"})
+
+	d.Notify(data.Change{Type: data.Deleted, Exhibition: data.Exhibition{ID: "1", Title: "Amber"}, Time: time.Now()})
+	failed := waitFor(t, d, s.ID, Failed)
+	if dead := d.DeadLetters(); len(dead) != 1 || dead[0].ID != failed.ID {
+		t.Fatalf("dead letters are %+v", dead)
+	}
+
+	if _, err := d.Retry(failed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if delivery := waitFor(t, d, s.ID, Delivered); len(delivery.Attempts) != 4 {
+		t.Fatalf("got attempts %+v", delivery.Attempts)
+	}
+	if dead := d.DeadLetters(); len(dead) != 0 {
+		t.Fatalf("dead letters are %+v", dead)
+	}
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	server, _ := receiver(t, "s3cret")
+	d, err := Open(path, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := d.Subscribe(Subscription{URL: server.URL, Secret: "s3cret"})
+	// Not started: the delivery is only queued.
+	d.Notify(data.Change{Type: data.Created, Exhibition: data.Exhibition{ID: "1", Title: "Amber"}, Time: time.Now()})
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Open(path, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if list, _ := d.Deliveries(s.ID); len(list) != 1 || list[0].Status != Pending {
+		t.Fatalf("after a restart got %+v", list)
+	}
+	d.Start()
+	waitFor(t, d, s.ID, Delivered)
+}
+
+func TestQueueAfterATornLineSurvives(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	d, err := Open(path, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := d.Subscribe(Subscription{URL: "http://127.0.0.1:1/hook", Secret: "s3cret"})
+	d.Notify(data.Change{Type: data.Created, Exhibition: data.Exhibition{ID: "1", Title: "Amber"}, Time: time.Now()})
+	d.Close()
+
+	// A crash in the middle of an append.
+	queue, err := os.OpenFile(path+".queue", os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.WriteString(`{"ID": "dlv_torn", "Sta`)
+	queue.Close()
+
+	for _, id := range []string{"2", "3"} {
+		if d, err = Open(path, fast); err != nil {
+			t.Fatal(err)
+		}
+		d.Notify(data.Change{Type: data.Created, Exhibition: data.Exhibition{ID: id, Title: "Jade"}, Time: time.Now()})
+		d.Close()
+	}
+	if d, err = Open(path, fast); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if list, _ := d.Deliveries(s.ID); len(list) != 3 {
+		t.Fatalf("got %d deliveries, want the 3 queued", len(list))
+	}
+}
+
+func TestCompactionKeepsWhatIsAppendedMeanwhile(t *testing.T) {
+	s, _, _, err := openStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	first := &Delivery{ID: "dlv_1", Status: Pending}
+	s.append(first)
+
+	content, err := s.snapshot([]*Delivery{first})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := s.writeSnapshot(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Written while the snapshot was, so it's not in it.
+	s.append(&Delivery{ID: "dlv_2", Status: Pending})
+	if err := s.swap(tmp); err != nil {
+		t.Fatal(err)
+	}
+	s.append(&Delivery{ID: "dlv_3", Status: Pending})
+
+	deliveries, err := s.replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 3 || deliveries[1].ID != "dlv_2" || deliveries[2].ID != "dlv_3" {
+		t.Fatalf("got %+v", deliveries)
+	}
+}
+
+func TestOpeningAndClosing(t *testing.T) {
+	d := New(fast)
+	s, _ := d.Subscribe(Subscription{URL: "http://partner.example/hook", Events: []string{ExhibitionOpened, ExhibitionClosed}})
+	now := time.Date(2024, 4, 6, 12, 0, 0, 0, time.UTC)
+	amber := data.Exhibition{ID: "1", Title: "Amber"}
+	tides := data.Exhibition{ID: "2", Title: "Tides", Schedule: &data.Schedule{Opens: "2024-04-07"}}
+	d.Track([]data.Exhibition{amber, tides})
+
+	amber.CurrentlyOpened = true
+	d.Notify(data.Change{Type: data.Updated, Exhibition: amber, Time: now})
+	d.Notify(data.Change{Type: data.Updated, Exhibition: amber, Time: now})
+	d.checkSchedules(now, now.Add(12*time.Hour))
+	amber.CurrentlyOpened = false
+	d.Notify(data.Change{Type: data.Updated, Exhibition: amber, Time: now.Add(13 * time.Hour)})
+
+	list, _ := d.Deliveries(s.ID)
+	var got []string
+	for i := len(list) - 1; i >= 0; i-- {
+		got = append(got, list[i].Event.Exhibition.Title+" "+list[i].Event.Type)
+	}
+	want := []string{"Amber exhibition.opened", "Tides exhibition.opened", "Amber exhibition.closed"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, s := range []Subscription{
+		{URL: ""},
+		{URL: "ftp://partner.example/hook"},
+		{URL: "/hook"},
+		{URL: "https://partner.example/hook", Events: []string{"exhibition.painted"}},
+	} {
+		if err := Validate(s); err == nil {
+			t.Errorf("%+v is valid", s)
+		}
+	}
+	if err := Validate(Subscription{URL: "https://partner.example/hook", Events: []string{ExhibitionOpened}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"type":"exhibition.created"}`)
+	header := Sign("s3cret", time.Now(), body)
+	if err := VerifySignature("s3cret", header, body, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if VerifySignature("other", header, body, time.Minute) == nil {
+		t.Error("a signature with another secret passed")
+	}
+	if VerifySignature("s3cret", header, []byte(`{}`), time.Minute) == nil {
+		t.Error("a signature of another body passed")
+	}
+	old := Sign("s3cret", time.Now().Add(-time.Hour), body)
+	if VerifySignature("s3cret", old, body, time.Minute) == nil {
+		t.Error("an old signature passed")
+	}
+}